@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/in-toto/attestation-verifier/verifier"
+	vsav1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	attestationv1 "github.com/in-toto/attestation/go/v1"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore-go/pkg/root"
 	"github.com/spf13/cobra"
 )
 
@@ -19,7 +26,20 @@ var rootCmd = &cobra.Command{
 var (
 	layoutPath      string
 	attestationsDir string
+	includePatterns []string
+	excludePatterns []string
 	parametersPath  string
+	subjectPaths    []string
+	workspace       string
+	layoutKeyPaths  []string
+	layoutThreshold int
+	outputFormat    string
+	vsaKeyPath      string
+	vsaOutputPath   string
+	vsaResourceURI  string
+	trustedRootPath string
+	ociLayoutPath   string
+	imageRef        string
 )
 
 func Execute() {
@@ -38,12 +58,40 @@ func init() {
 		"Layout to use for verification",
 	)
 
+	rootCmd.Flags().StringArrayVar(
+		&layoutKeyPaths,
+		"layout-key",
+		[]string{},
+		"Public key trusted to sign the layout, may be specified multiple times",
+	)
+
+	rootCmd.Flags().IntVar(
+		&layoutThreshold,
+		"layout-threshold",
+		1,
+		"Number of layout keys that must sign the layout",
+	)
+
 	rootCmd.Flags().StringVarP(
 		&attestationsDir,
 		"attestations-directory",
 		"a",
 		"",
-		"Directory to load attestations from",
+		"Directory, file, or .tar, .tar.gz, or .zip archive to load attestations from, - to read them from stdin, or the URL of an attestation store",
+	)
+
+	rootCmd.Flags().StringArrayVar(
+		&includePatterns,
+		"include",
+		[]string{},
+		"Glob for the files to load attestations from, may be specified multiple times (default *.json and *.jsonl)",
+	)
+
+	rootCmd.Flags().StringArrayVar(
+		&excludePatterns,
+		"exclude",
+		[]string{},
+		"Glob for the files and directories not to load attestations from, may be specified multiple times",
 	)
 
 	rootCmd.Flags().StringVar(
@@ -53,59 +101,230 @@ func init() {
 		"Path to JSON file containing key-value string pairs for parameter substitution in the layout",
 	)
 
+	rootCmd.Flags().StringArrayVarP(
+		&subjectPaths,
+		"subject",
+		"s",
+		[]string{},
+		"Final artifact to verify against the layout's subjects, may be specified multiple times",
+	)
+
+	rootCmd.Flags().StringVar(
+		&workspace,
+		"workspace",
+		".",
+		"Directory to run the layout's inspections in",
+	)
+
+	rootCmd.Flags().StringVarP(
+		&outputFormat,
+		"output",
+		"o",
+		"text",
+		"Output format, one of text or json",
+	)
+
+	rootCmd.Flags().StringVar(
+		&vsaKeyPath,
+		"vsa-key",
+		"",
+		"Private key to sign a verification summary attestation with after successful verification",
+	)
+
+	rootCmd.Flags().StringVar(
+		&vsaOutputPath,
+		"vsa-output",
+		"vsa.intoto.json",
+		"Path to write the signed verification summary attestation to",
+	)
+
+	rootCmd.Flags().StringVar(
+		&vsaResourceURI,
+		"vsa-resource-uri",
+		"",
+		"URI of the verified resource to record in the verification summary attestation",
+	)
+
+	rootCmd.Flags().StringVar(
+		&trustedRootPath,
+		"trusted-root",
+		"",
+		"Path to a Sigstore trusted_root.json used to verify Sigstore functionaries",
+	)
+
+	rootCmd.Flags().StringVar(
+		&ociLayoutPath,
+		"oci-layout",
+		"",
+		"OCI image layout directory to load the attestations attached to --image from",
+	)
+
+	rootCmd.Flags().StringVar(
+		&imageRef,
+		"image",
+		"",
+		"Digest or tag of the image in --oci-layout to verify, which becomes a final artifact",
+	)
+
 	rootCmd.MarkFlagRequired("layout")
-	rootCmd.MarkFlagRequired("attestations-directory")
 }
 
 func verify(cmd *cobra.Command, args []string) error {
-	layout, err := verifier.LoadLayout(layoutPath)
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("invalid output format %s", outputFormat)
+	}
+
+	if len(attestationsDir) == 0 && len(ociLayoutPath) == 0 {
+		return fmt.Errorf("either an attestations directory or an OCI layout is required")
+	}
+
+	if len(ociLayoutPath) > 0 && len(imageRef) == 0 {
+		return fmt.Errorf("an image is required to verify with an OCI layout")
+	}
+
+	if len(vsaKeyPath) > 0 && len(subjectPaths) == 0 && len(imageRef) == 0 {
+		return fmt.Errorf("at least one subject is required to generate a verification summary attestation")
+	}
+
+	var (
+		layout *verifier.Layout
+		err    error
+	)
+	if len(layoutKeyPaths) > 0 {
+		layoutVerifiers := []dsse.Verifier{}
+		for _, keyPath := range layoutKeyPaths {
+			layoutVerifier, err := verifier.LoadVerifier(keyPath)
+			if err != nil {
+				return err
+			}
+			layoutVerifiers = append(layoutVerifiers, layoutVerifier)
+		}
+
+		layout, err = verifier.LoadSignedLayout(layoutPath, layoutThreshold, layoutVerifiers...)
+	} else {
+		layout, err = verifier.LoadLayout(layoutPath)
+	}
 	if err != nil {
 		return err
 	}
 
-	dirEntries, err := os.ReadDir(attestationsDir)
+	parameters := map[string]string{}
+	if len(parametersPath) > 0 {
+		contents, err := os.ReadFile(parametersPath)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(contents, &parameters); err != nil {
+			return err
+		}
+	}
+
+	subjects := []*attestationv1.ResourceDescriptor{}
+	for _, subjectPath := range subjectPaths {
+		contents, err := os.ReadFile(subjectPath)
+		if err != nil {
+			return err
+		}
+
+		digest := sha256.Sum256(contents)
+		subjects = append(subjects, &attestationv1.ResourceDescriptor{
+			Name:   filepath.ToSlash(filepath.Clean(subjectPath)),
+			Digest: map[string]string{"sha256": hex.EncodeToString(digest[:])},
+		})
+	}
+
+	attestations := map[string]*verifier.Attestation{}
+	switch {
+	case strings.HasPrefix(attestationsDir, "http://") || strings.HasPrefix(attestationsDir, "https://"):
+		// Stores are queried for the attestations about the subjects,
+		// rather than downloaded whole.
+		source := verifier.NewHTTPSource(attestationsDir, nil)
+		if len(subjects) > 0 {
+			attestations, err = verifier.FetchAttestations(cmd.Context(), source, subjects)
+		} else {
+			attestations, err = source.List(cmd.Context())
+		}
+	case len(attestationsDir) > 0:
+		attestations, err = verifier.NewFileSystemSource(attestationsDir, includePatterns, excludePatterns).List(cmd.Context())
+	}
 	if err != nil {
 		return err
 	}
 
-	attestations := map[string]*dsse.Envelope{}
-	for _, e := range dirEntries {
-		name := e.Name()
-		ab, err := os.ReadFile(filepath.Join(attestationsDir, name))
+	if len(ociLayoutPath) > 0 {
+		source := verifier.NewOCILayoutSource(ociLayoutPath)
+		image, err := source.Resolve(imageRef)
 		if err != nil {
 			return err
 		}
-		// attestation := &attestationv1.Statement{}
-		// if err := json.Unmarshal(ab, attestation); err != nil {
-		// 	return err
-		// }
-		// encodedBytes, err := cjson.EncodeCanonical(attestation)
-		// if err != nil {
-		// 	return err
-		// }
-		// envelope := &dsse.Envelope{
-		// 	Payload:     base64.StdEncoding.EncodeToString(encodedBytes),
-		// 	PayloadType: "application/vnd.in-toto+json",
-		// }
-		envelope := &dsse.Envelope{}
-		if err := json.Unmarshal(ab, envelope); err != nil {
+		subjects = append(subjects, image)
+
+		imageAttestations, err := verifier.FetchAttestations(cmd.Context(), source, []*attestationv1.ResourceDescriptor{image})
+		if err != nil {
 			return err
 		}
-
-		attestations[strings.TrimSuffix(name, ".json")] = envelope
+		maps.Copy(attestations, imageAttestations)
 	}
 
-	parameters := map[string]string{}
-	if len(parametersPath) > 0 {
-		contents, err := os.ReadFile(parametersPath)
+	opts := []verifier.VerifyOption{verifier.WithSubjects(subjects...), verifier.WithWorkspace(workspace)}
+	if len(trustedRootPath) > 0 {
+		trustedRoot, err := root.NewTrustedRootFromPath(trustedRootPath)
 		if err != nil {
 			return err
 		}
+		opts = append(opts, verifier.WithTrustedRoot(trustedRoot))
+	}
 
-		if err := json.Unmarshal(contents, &parameters); err != nil {
+	report, err := verifier.Verify(layout, attestations, parameters, opts...)
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
 			return err
 		}
 	}
+	if err != nil {
+		return err
+	}
+
+	if len(vsaKeyPath) > 0 {
+		return writeVSA(layout, attestations, subjects, report)
+	}
+
+	return nil
+}
+
+func writeVSA(layout *verifier.Layout, attestations map[string]*verifier.Attestation, subjects []*attestationv1.ResourceDescriptor, report *verifier.Report) error {
+	signer, err := verifier.LoadSigner(vsaKeyPath)
+	if err != nil {
+		return err
+	}
+
+	layoutBytes, err := os.ReadFile(layoutPath)
+	if err != nil {
+		return err
+	}
+	layoutDigest := sha256.Sum256(layoutBytes)
+	policy := &vsav1.VerificationSummary_Policy{
+		Uri:    layoutPath,
+		Digest: map[string]string{"sha256": hex.EncodeToString(layoutDigest[:])},
+	}
+
+	statement, err := verifier.GenerateVSA(layout, policy, vsaResourceURI, subjects, attestations, report)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := verifier.SignStatement(statement, signer)
+	if err != nil {
+		return err
+	}
+
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
 
-	return verifier.Verify(layout, attestations, parameters)
+	return os.WriteFile(vsaOutputPath, envelopeBytes, 0o644)
 }
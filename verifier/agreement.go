@@ -0,0 +1,149 @@
+package verifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// applyAgreement narrows the accepted claims down to the largest group, by
+// distinct owners, whose claims agree with each other. Claims agree if they
+// have the same subjects and materials, and the same values for each of the
+// agreement's fields. Accepted claims outside the group, or whose fields can't
+// be evaluated, are rejected, and the reasons are returned.
+func applyAgreement(logger *log.Entry, env *cel.Env, agreement *Agreement, statements map[string]*attestationv1.Statement, defaultArtifacts string, predicateReport *PredicateReport) ([]error, error) {
+	logger.Info("Checking that claims agree...")
+
+	programs := make([]cel.Program, 0, len(agreement.Fields))
+	for _, field := range agreement.Fields {
+		ast, issues := env.Compile(field)
+		if issues != nil && issues.Err() != nil {
+			return nil, issues.Err()
+		}
+
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+	}
+
+	disagreements := []error{}
+	keys := []string{}
+	groups := map[string][]*ClaimReport{}
+	for _, claimReport := range predicateReport.Claims {
+		if !claimReport.Accepted {
+			continue
+		}
+
+		key, err := getAgreementKey(statements[claimReport.Functionary], programs, defaultArtifacts)
+		if err != nil {
+			// A claim that can't be compared can't be shown to agree, so
+			// it's rejected and the threshold decides.
+			err = fmt.Errorf("unable to compare claim by %s: %w", claimReport.Functionary, err)
+			claimReport.Accepted = false
+			claimReport.Errors = append(claimReport.Errors, err.Error())
+			disagreements = append(disagreements, err)
+			continue
+		}
+
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], claimReport)
+	}
+
+	var agreeing []*ClaimReport
+	agreeingOwners := []string{}
+	for _, key := range keys {
+		owners := []string{}
+		for _, claimReport := range groups[key] {
+			if !slices.Contains(owners, claimReport.Owner) {
+				owners = append(owners, claimReport.Owner)
+			}
+		}
+
+		if len(owners) > len(agreeingOwners) {
+			agreeing = groups[key]
+			agreeingOwners = owners
+		}
+	}
+
+	agreeingFunctionaries := []string{}
+	for _, claimReport := range agreeing {
+		agreeingFunctionaries = append(agreeingFunctionaries, claimReport.Functionary)
+	}
+
+	for _, claimReport := range predicateReport.Claims {
+		if !claimReport.Accepted || slices.Contains(agreeing, claimReport) {
+			continue
+		}
+
+		err := fmt.Errorf("claim by %s does not agree with the claims by %s", claimReport.Functionary, strings.Join(agreeingFunctionaries, ", "))
+		claimReport.Accepted = false
+		claimReport.Errors = append(claimReport.Errors, err.Error())
+		disagreements = append(disagreements, err)
+	}
+
+	predicateReport.Owners = agreeingOwners
+	predicateReport.Accepted = len(agreeingOwners)
+	logger.Info("Done.")
+
+	return disagreements, nil
+}
+
+// getAgreementKey serializes everything about the statement that must be
+// identical for claims to agree.
+func getAgreementKey(statement *attestationv1.Statement, programs []cel.Program, defaultArtifacts string) (string, error) {
+	materials, _, err := getMaterialsAndProducts(statement, defaultArtifacts)
+	if err != nil {
+		return "", err
+	}
+
+	input, err := getActivation(statement)
+	if err != nil {
+		return "", err
+	}
+
+	fields := []any{}
+	for _, program := range programs {
+		out, _, err := program.Eval(input)
+		if err != nil {
+			return "", err
+		}
+
+		value, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+		if err != nil {
+			return "", err
+		}
+
+		fields = append(fields, value.(*structpb.Value).AsInterface())
+	}
+
+	key, err := json.Marshal(map[string]any{
+		"subjects":  getArtifactDigests(statement.Subject),
+		"materials": getArtifactDigests(materials),
+		"fields":    fields,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+func getArtifactDigests(artifacts []*attestationv1.ResourceDescriptor) map[string]map[string]string {
+	digests := map[string]map[string]string{}
+	for _, artifact := range artifacts {
+		digests[artifact.Name] = artifact.Digest
+	}
+
+	return digests
+}
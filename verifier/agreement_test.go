@@ -0,0 +1,130 @@
+package verifier
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestApplyAgreement(t *testing.T) {
+	env, err := getCELEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement := func(subjectDigest string, predicate map[string]any) *attestationv1.Statement {
+		predicateStruct, err := structpb.NewStruct(predicate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &attestationv1.Statement{
+			PredicateType: "https://example.com/test/v1",
+			Subject:       []*attestationv1.ResourceDescriptor{{Name: "app.tgz", Digest: map[string]string{"sha256": subjectDigest}}},
+			Predicate:     predicateStruct,
+		}
+	}
+	built := func(builder string) map[string]any { return map[string]any{"builder": builder} }
+
+	tests := map[string]struct {
+		fields     []string
+		statements map[string]*attestationv1.Statement
+		owners     map[string]string
+		want       []string
+		wantErrs   []string
+	}{
+		"all agree": {
+			statements: map[string]*attestationv1.Statement{
+				"alice": statement("aa", built("x")),
+				"bob":   statement("aa", built("y")),
+			},
+			want: []string{"alice", "bob"},
+		},
+		"different subjects": {
+			statements: map[string]*attestationv1.Statement{
+				"alice": statement("aa", built("x")),
+				"bob":   statement("aa", built("x")),
+				"carol": statement("bb", built("x")),
+			},
+			want:     []string{"alice", "bob"},
+			wantErrs: []string{"claim by carol does not agree with the claims by alice, bob"},
+		},
+		"different fields": {
+			fields: []string{"predicate.builder"},
+			statements: map[string]*attestationv1.Statement{
+				"alice": statement("aa", built("x")),
+				"bob":   statement("aa", built("y")),
+				"carol": statement("aa", built("y")),
+			},
+			want:     []string{"bob", "carol"},
+			wantErrs: []string{"claim by alice does not agree with the claims by bob, carol"},
+		},
+		"largest group by owners": {
+			fields: []string{"predicate.builder"},
+			statements: map[string]*attestationv1.Statement{
+				"alice":  statement("aa", built("x")),
+				"bob":    statement("aa", built("x")),
+				"carol":  statement("aa", built("y")),
+				"carol2": statement("aa", built("y")),
+				"carol3": statement("aa", built("y")),
+			},
+			owners:   map[string]string{"carol2": "carol", "carol3": "carol"},
+			want:     []string{"alice", "bob"},
+			wantErrs: []string{"claim by carol does not agree", "claim by carol2 does not agree", "claim by carol3 does not agree"},
+		},
+		"field that can't be evaluated": {
+			fields: []string{"predicate.builder"},
+			statements: map[string]*attestationv1.Statement{
+				"alice": statement("aa", built("x")),
+				"bob":   statement("aa", built("x")),
+				"carol": statement("aa", map[string]any{}),
+			},
+			want:     []string{"alice", "bob"},
+			wantErrs: []string{"unable to compare claim by carol"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			predicateReport := &PredicateReport{Owners: []string{}}
+			for _, functionary := range slices.Sorted(maps.Keys(test.statements)) {
+				owner := functionary
+				if test.owners[functionary] != "" {
+					owner = test.owners[functionary]
+				}
+				predicateReport.Claims = append(predicateReport.Claims, &ClaimReport{Functionary: functionary, Owner: owner, Accepted: true})
+				predicateReport.accept(owner)
+			}
+
+			disagreements, err := applyAgreement(log.NewEntry(log.New()), env, &Agreement{Fields: test.fields}, test.statements, defaultArtifactsNone, predicateReport)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := predicateReport.acceptedFunctionaries(); !slices.Equal(got, test.want) {
+				t.Errorf("got accepted functionaries %v, want %v", got, test.want)
+			}
+			if predicateReport.Accepted != len(test.want) {
+				t.Errorf("got %d accepted owners, want %d", predicateReport.Accepted, len(test.want))
+			}
+
+			if len(disagreements) != len(test.wantErrs) {
+				t.Fatalf("got disagreements %v, want %v", disagreements, test.wantErrs)
+			}
+			for i, disagreement := range disagreements {
+				if !strings.Contains(disagreement.Error(), test.wantErrs[i]) {
+					t.Errorf("got disagreement %v, want %q", disagreement, test.wantErrs[i])
+				}
+			}
+			for _, claimReport := range predicateReport.Claims {
+				if !claimReport.Accepted && len(claimReport.Errors) == 0 {
+					t.Errorf("rejected claim by %s has no errors", claimReport.Functionary)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package verifier
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+)
+
+// Attestation is a DSSE envelope along with the material needed to verify its
+// signature that's distributed alongside it rather than in the layout.
+type Attestation struct {
+	Envelope *dsse.Envelope
+
+	// Raw is the attestation as it was read, either a DSSE envelope or a
+	// Sigstore bundle, which verification summaries identify it by.
+	Raw []byte
+
+	// Certificates is the chain of the certificate that signed the envelope,
+	// leaf first.
+	Certificates []*x509.Certificate
+
+	// Timestamps are RFC 3161 signed timestamps over the envelope's
+	// signature.
+	Timestamps [][]byte
+
+	// TlogEntries are the transparency log entries recorded for the
+	// envelope.
+	TlogEntries []*tlog.Entry
+}
+
+// decodeSignature decodes a DSSE signature, which may use either standard or
+// URL-safe base64.
+func decodeSignature(sig dsse.Signature) ([]byte, error) {
+	sigBytes, err := base64.StdEncoding.DecodeString(sig.Sig)
+	if err != nil {
+		return base64.URLEncoding.DecodeString(sig.Sig)
+	}
+
+	return sigBytes, nil
+}
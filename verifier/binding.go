@@ -0,0 +1,155 @@
+package verifier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// Step bindings decide which step an attestation is a claim for.
+const (
+	// stepBindingFilename takes the step name from the attestation's file
+	// name, `<step>.<anything>.json`. This is the default.
+	stepBindingFilename = "filename"
+	// stepBindingPredicate takes the step name from the predicate's `name`
+	// field, as recorded in links.
+	stepBindingPredicate = "predicate"
+	// stepBindingContent binds attestations to every step whose binding
+	// they match.
+	stepBindingContent = "content"
+)
+
+// stepBinder finds the steps that an attestation's statement is a claim for,
+// according to the layout's step binding.
+type stepBinder struct {
+	mode      string
+	steps     []*Step
+	selectors map[string]cel.Program
+}
+
+func newStepBinder(env *cel.Env, layout *Layout) (*stepBinder, error) {
+	binder := &stepBinder{mode: layout.StepBinding, steps: layout.Steps, selectors: map[string]cel.Program{}}
+	if binder.mode == "" {
+		binder.mode = stepBindingFilename
+	}
+
+	switch binder.mode {
+	case stepBindingFilename, stepBindingPredicate:
+		return binder, nil
+	case stepBindingContent:
+	default:
+		return nil, fmt.Errorf("unknown step binding %s", binder.mode)
+	}
+
+	for _, step := range layout.Steps {
+		if step.Binding == nil {
+			continue
+		}
+		if step.Binding.Selector == "" && step.Binding.PredicateType == "" && len(step.Binding.Subjects) == 0 {
+			return nil, fmt.Errorf("binding for step %s matches every attestation", step.Name)
+		}
+		if step.Binding.Selector == "" {
+			continue
+		}
+
+		ast, issues := env.Compile(step.Binding.Selector)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("invalid selector for step %s: %w", step.Name, issues.Err())
+		}
+
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector for step %s: %w", step.Name, err)
+		}
+		binder.selectors[step.Name] = program
+	}
+
+	return binder, nil
+}
+
+// getStepNames returns the names of the steps the statement is a claim for.
+func (b *stepBinder) getStepNames(logger *log.Entry, attestationName string, statement *attestationv1.Statement) ([]string, error) {
+	switch b.mode {
+	case stepBindingPredicate:
+		name := statement.GetPredicate().GetFields()["name"].GetStringValue()
+		if name == "" {
+			logger.Infof("Predicate of %s has no step name", attestationName)
+			return nil, nil
+		}
+		return []string{name}, nil
+
+	case stepBindingContent:
+		stepNames := []string{}
+		for _, step := range b.steps {
+			if step.Binding == nil {
+				continue
+			}
+
+			bound, err := b.isBound(step, statement)
+			if err != nil {
+				return nil, err
+			}
+			if bound {
+				stepNames = append(stepNames, step.Name)
+			}
+		}
+		if len(stepNames) == 0 {
+			logger.Infof("%s does not match the binding of any step", attestationName)
+		}
+		return stepNames, nil
+
+	default:
+		return []string{getStepName(attestationName)}, nil
+	}
+}
+
+// isBound reports whether the statement has the predicate type of the step's
+// binding, has a subject matching each of its subject patterns, and is
+// selected by its selector.
+func (b *stepBinder) isBound(step *Step, statement *attestationv1.Statement) (bool, error) {
+	binding := step.Binding
+	if binding.PredicateType != "" && binding.PredicateType != statement.PredicateType {
+		return false, nil
+	}
+
+	for _, pattern := range binding.Subjects {
+		matched := false
+		for _, subject := range statement.Subject {
+			if ok, err := match(pattern, subject.Name); err == nil && ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	selector, ok := b.selectors[step.Name]
+	if !ok {
+		return true, nil
+	}
+
+	input, err := getActivation(statement)
+	if err != nil {
+		return false, err
+	}
+
+	out, _, err := selector.Eval(input)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such attribute") || strings.Contains(err.Error(), "no such key") {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to evaluate selector for step %s: %w", step.Name, err)
+	}
+
+	selected, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("selector for step %s does not evaluate to a boolean", step.Name)
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,139 @@
+package verifier
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestStepBinder(t *testing.T) {
+	env, err := getCELEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement := func(predicateType, subject string, predicate map[string]any) *attestationv1.Statement {
+		predicateStruct, err := structpb.NewStruct(predicate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &attestationv1.Statement{
+			PredicateType: predicateType,
+			Subject:       []*attestationv1.ResourceDescriptor{{Name: subject, Digest: map[string]string{"sha256": "aa"}}},
+			Predicate:     predicateStruct,
+		}
+	}
+	link := statement(linkPredicateType, "src/main.go", map[string]any{"name": "clone"})
+	provenance := statement("https://slsa.dev/provenance/v1", "app.tgz", map[string]any{"builder": "github"})
+	otherProvenance := statement("https://slsa.dev/provenance/v1", "app.tgz", map[string]any{"builder": "gitlab"})
+	scan := statement("https://example.com/scan/v1", "app.tgz", map[string]any{})
+
+	contentSteps := []*Step{
+		{Name: "clone", Binding: &StepBinding{PredicateType: linkPredicateType}},
+		{Name: "build", Binding: &StepBinding{PredicateType: "https://slsa.dev/provenance/v1", Subjects: []string{"*.tgz"}, Selector: `predicate.builder == "github"`}},
+		{Name: "release", Binding: &StepBinding{Subjects: []string{"*.tgz"}}},
+		{Name: "test"},
+	}
+
+	tests := map[string]struct {
+		layout          *Layout
+		attestationName string
+		statement       *attestationv1.Statement
+		want            []string
+		wantErr         string
+	}{
+		"filename by default": {
+			layout:          &Layout{},
+			attestationName: "clone.fe1c6281",
+			statement:       link,
+			want:            []string{"clone"},
+		},
+		"filename in a directory": {
+			layout:          &Layout{StepBinding: stepBindingFilename},
+			attestationName: "attestations/build.fe1c6281-0",
+			statement:       provenance,
+			want:            []string{"build"},
+		},
+		"predicate name": {
+			layout:          &Layout{StepBinding: stepBindingPredicate},
+			attestationName: "anything",
+			statement:       link,
+			want:            []string{"clone"},
+		},
+		"predicate without a name": {
+			layout:          &Layout{StepBinding: stepBindingPredicate},
+			attestationName: "build.fe1c6281",
+			statement:       provenance,
+		},
+		"content by predicate type": {
+			layout:    &Layout{StepBinding: stepBindingContent, Steps: contentSteps},
+			statement: link,
+			want:      []string{"clone"},
+		},
+		"content by every condition": {
+			layout:    &Layout{StepBinding: stepBindingContent, Steps: contentSteps},
+			statement: provenance,
+			want:      []string{"build", "release"},
+		},
+		"content rejected by selector": {
+			layout:    &Layout{StepBinding: stepBindingContent, Steps: contentSteps},
+			statement: otherProvenance,
+			want:      []string{"release"},
+		},
+		"content with a selector on a missing field": {
+			layout:    &Layout{StepBinding: stepBindingContent, Steps: contentSteps},
+			statement: scan,
+			want:      []string{"release"},
+		},
+		"content bound to no step": {
+			layout:    &Layout{StepBinding: stepBindingContent, Steps: contentSteps},
+			statement: statement("https://example.com/scan/v1", "report.json", map[string]any{}),
+		},
+		"content with a binding that matches everything": {
+			layout:  &Layout{StepBinding: stepBindingContent, Steps: []*Step{{Name: "build", Binding: &StepBinding{}}}},
+			wantErr: "binding for step build matches every attestation",
+		},
+		"content with an invalid selector": {
+			layout:  &Layout{StepBinding: stepBindingContent, Steps: []*Step{{Name: "build", Binding: &StepBinding{Selector: "predicate."}}}},
+			wantErr: "invalid selector for step build",
+		},
+		"content with a selector that isn't a boolean": {
+			layout:    &Layout{StepBinding: stepBindingContent, Steps: []*Step{{Name: "build", Binding: &StepBinding{Selector: "predicateType"}}}},
+			statement: provenance,
+			wantErr:   "selector for step build does not evaluate to a boolean",
+		},
+		"unknown": {
+			layout:  &Layout{StepBinding: "subject"},
+			wantErr: "unknown step binding subject",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			stepNames, err := func() ([]string, error) {
+				binder, err := newStepBinder(env, test.layout)
+				if err != nil {
+					return nil, err
+				}
+				return binder.getStepNames(log.NewEntry(log.New()), test.attestationName, test.statement)
+			}()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(stepNames, test.want) {
+				t.Errorf("got steps %v, want %v", stepNames, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore/pkg/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// x509CAKeyType identifies functionaries that are any certificate issued by
+// a CA, rather than a single key.
+const x509CAKeyType = "x509-ca"
+
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"ocspSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// certificateVerifier verifies signatures with the public key of a
+// certificate. It has no key ID of its own, as the certificate may satisfy
+// several functionaries.
+type certificateVerifier struct {
+	certificate *x509.Certificate
+	verifier    signature.Verifier
+}
+
+func newCertificateVerifier(cert *x509.Certificate) (*certificateVerifier, error) {
+	verifier, err := signature.LoadDefaultVerifier(cert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certificateVerifier{certificate: cert, verifier: verifier}, nil
+}
+
+func (v *certificateVerifier) Verify(_ context.Context, data, sig []byte) error {
+	return v.verifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(data))
+}
+
+func (v *certificateVerifier) KeyID() (string, error) {
+	return "", nil
+}
+
+func (v *certificateVerifier) Public() crypto.PublicKey {
+	return v.certificate.PublicKey
+}
+
+func isCertificateKeyType(keyType string) bool {
+	return keyType == sigstoreKeyType || keyType == x509CAKeyType
+}
+
+// getCertificateKeys verifies the envelope's signature with the attestation's
+// signing certificate and, if it verifies, accepts it for every
+// certificate-based functionary the certificate satisfies, in the order of
+// their names. The signature is checked once, outside of the key verifiers,
+// as a DSSE envelope verifier would only attribute it to one of them.
+func getCertificateKeys(logger *log.Entry, functionaries map[string]Functionary, attestation *Attestation, logEntries []*tlog.Entry, trustedRoot root.TrustedMaterial) ([]dsse.AcceptedKey, error) {
+	if len(attestation.Certificates) == 0 {
+		return nil, nil
+	}
+	leaf := attestation.Certificates[0]
+
+	keyIDs := []string{}
+	for _, name := range slices.Sorted(maps.Keys(functionaries)) {
+		functionary := functionaries[name]
+
+		var err error
+		switch functionary.KeyType {
+		case sigstoreKeyType:
+			err = verifySigstoreIdentity(functionary, attestation, logEntries, trustedRoot)
+		case x509CAKeyType:
+			err = verifyCertificateChain(functionary, attestation, logEntries, trustedRoot)
+		default:
+			continue
+		}
+		if err != nil {
+			logger.Infof("Certificate does not match functionary %s: %s", functionary.KeyID, err)
+			continue
+		}
+
+		keyIDs = append(keyIDs, functionary.KeyID)
+	}
+	if len(keyIDs) == 0 {
+		return nil, nil
+	}
+
+	verifier, err := newCertificateVerifier(leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	envVerifier, err := dsse.NewEnvelopeVerifier(verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificate binds the signature to the functionaries, so a key ID
+	// hint set by the signer doesn't apply.
+	accepted, err := envVerifier.Verify(context.Background(), withoutKeyIDs(attestation.Envelope))
+	if err != nil {
+		logger.Infof("Signature does not match the certificate: %s", err)
+		return nil, nil
+	}
+
+	acceptedKeys := make([]dsse.AcceptedKey, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		acceptedKeys = append(acceptedKeys, dsse.AcceptedKey{Public: leaf.PublicKey, KeyID: keyID, Sig: accepted[0].Sig})
+	}
+
+	return acceptedKeys, nil
+}
+
+func withoutKeyIDs(envelope *dsse.Envelope) *dsse.Envelope {
+	signatures := make([]dsse.Signature, 0, len(envelope.Signatures))
+	for _, sig := range envelope.Signatures {
+		signatures = append(signatures, dsse.Signature{Sig: sig.Sig})
+	}
+
+	return &dsse.Envelope{PayloadType: envelope.PayloadType, Payload: envelope.Payload, Signatures: signatures}
+}
+
+// verifyCertificateChain checks that the attestation's leaf certificate
+// chains to one of the functionary's CA certificates, through any
+// intermediates that came with it, and that it meets the functionary's
+// certificate constraints. The chain must be valid when the envelope was
+// signed, according to any verified timestamps or transparency log entries,
+// or else at the time of verification.
+func verifyCertificateChain(functionary Functionary, attestation *Attestation, logEntries []*tlog.Entry, trustedRoot root.TrustedMaterial) error {
+	leaf := attestation.Certificates[0]
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(functionary.KeyVal.Certificate)) {
+		return fmt.Errorf("functionary has no CA certificates")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range attestation.Certificates[1:] {
+		intermediates.AddCert(intermediate)
+	}
+
+	keyUsages := []x509.ExtKeyUsage{}
+	for _, name := range functionary.CertificateConstraints.ExtKeyUsages {
+		keyUsage, ok := extKeyUsages[name]
+		if !ok {
+			return fmt.Errorf("unknown extended key usage %s", name)
+		}
+		keyUsages = append(keyUsages, keyUsage)
+	}
+	if len(keyUsages) == 0 {
+		keyUsages = append(keyUsages, x509.ExtKeyUsageAny)
+	}
+
+	signingTimes, err := getSigningTimes(attestation, logEntries, trustedRoot)
+	if err != nil {
+		return err
+	}
+	if len(signingTimes) == 0 {
+		signingTimes = append(signingTimes, time.Now())
+	}
+
+	for _, signingTime := range signingTimes {
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     keyUsages,
+			CurrentTime:   signingTime,
+		}
+		if _, err := leaf.Verify(opts); err != nil {
+			return fmt.Errorf("certificate not valid at %s: %w", signingTime, err)
+		}
+	}
+
+	return functionary.CertificateConstraints.check(leaf)
+}
+
+// check tests the certificate's subject and SANs against the constraints.
+// Fields that are left empty are unconstrained. Otherwise, the certificate
+// must have at least one value for the field, and every value must match one
+// of the constraint's patterns.
+func (c CertificateConstraints) check(cert *x509.Certificate) error {
+	if len(c.CommonName) > 0 {
+		if err := checkCertificateValues("common name", []string{c.CommonName}, []string{cert.Subject.CommonName}); err != nil {
+			return err
+		}
+	}
+
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	for _, constraint := range []struct {
+		name     string
+		patterns []string
+		values   []string
+	}{
+		{"organization", c.Organizations, cert.Subject.Organization},
+		{"DNS name", c.DNSNames, cert.DNSNames},
+		{"email", c.Emails, cert.EmailAddresses},
+		{"URI", c.URIs, uris},
+	} {
+		if len(constraint.patterns) == 0 {
+			continue
+		}
+
+		if err := checkCertificateValues(constraint.name, constraint.patterns, constraint.values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkCertificateValues(name string, patterns, values []string) error {
+	if len(values) == 0 || (len(values) == 1 && len(values[0]) == 0) {
+		return fmt.Errorf("certificate has no %s, expected one matching %v", name, patterns)
+	}
+
+	for _, value := range values {
+		matched := false
+		for _, pattern := range patterns {
+			if ok, err := match(pattern, value); err == nil && ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("certificate has unexpected %s %s, expected one matching %v", name, value, patterns)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package verifier
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	log "github.com/sirupsen/logrus"
+)
+
+const testStatement = `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "app.tgz", "digest": {"sha256": "aa"}}], "predicateType": "https://example.com/test/v1", "predicate": {"name": "build"}}`
+
+// testCA is a CA that issues certificates for tests.
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, commonName string) *testCA {
+	t.Helper()
+
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	return &testCA{certificate: newTestCertificate(t, template, template, key, key), key: key}
+}
+
+// issue returns a certificate for key with the template's subject, SANs
+// and validity, which default to an hour either side of now.
+func (ca *testCA) issue(t *testing.T, template *x509.Certificate, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	if len(template.ExtKeyUsage) == 0 {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	}
+
+	return newTestCertificate(t, template, ca.certificate, key, ca.key)
+}
+
+func (ca *testCA) pem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}))
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newTestCertificate(t *testing.T, template, parent *x509.Certificate, key crypto.Signer, parentKey crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate
+}
+
+// newSignedTestEnvelope returns a DSSE envelope of the statement signed by
+// the ECDSA key.
+func newSignedTestEnvelope(t *testing.T, statement string, key *ecdsa.PrivateKey, keyID string) *dsse.Envelope {
+	t.Helper()
+
+	payloadType := "application/vnd.in-toto+json"
+	digest := sha256.Sum256(dsse.PAE(payloadType, []byte(statement)))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &dsse.Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString([]byte(statement)),
+		Signatures:  []dsse.Signature{{KeyID: keyID, Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}
+}
+
+func TestGetCertificateKeys(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	otherCA := newTestCA(t, "other CA")
+
+	key := newTestKey(t)
+	leaf := ca.issue(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "release-agent", Organization: []string{"example"}},
+		DNSNames:       []string{"ci.example.com"},
+		EmailAddresses: []string{"release@example.com"},
+	}, key)
+	envelope := newSignedTestEnvelope(t, testStatement, key, "")
+
+	caFunctionary := func(keyID string, constraints CertificateConstraints) Functionary {
+		return Functionary{KeyType: x509CAKeyType, KeyID: keyID, KeyVal: KeyVal{Certificate: ca.pem()}, CertificateConstraints: constraints}
+	}
+
+	tests := map[string]struct {
+		functionaries map[string]Functionary
+		envelope      *dsse.Envelope
+		want          []string
+	}{
+		"every satisfied functionary": {
+			functionaries: map[string]Functionary{
+				"any-agent":     caFunctionary("any-agent", CertificateConstraints{}),
+				"release-agent": caFunctionary("release-agent", CertificateConstraints{CommonName: "release-agent"}),
+				"ci":            caFunctionary("ci", CertificateConstraints{DNSNames: []string{"*.example.com"}, Organizations: []string{"example"}}),
+			},
+			want: []string{"any-agent", "ci", "release-agent"},
+		},
+		"common name": {
+			functionaries: map[string]Functionary{"build-agent": caFunctionary("build-agent", CertificateConstraints{CommonName: "build-agent"})},
+		},
+		"organization": {
+			functionaries: map[string]Functionary{"agent": caFunctionary("agent", CertificateConstraints{Organizations: []string{"other"}})},
+		},
+		"DNS name": {
+			functionaries: map[string]Functionary{"agent": caFunctionary("agent", CertificateConstraints{DNSNames: []string{"*.example.org"}})},
+		},
+		"email": {
+			functionaries: map[string]Functionary{"agent": caFunctionary("agent", CertificateConstraints{Emails: []string{"release@example.com"}})},
+			want:          []string{"agent"},
+		},
+		"missing URI": {
+			functionaries: map[string]Functionary{"agent": caFunctionary("agent", CertificateConstraints{URIs: []string{"https://*"}})},
+		},
+		"extended key usage": {
+			functionaries: map[string]Functionary{"agent": caFunctionary("agent", CertificateConstraints{ExtKeyUsages: []string{"serverAuth"}})},
+		},
+		"other CA": {
+			functionaries: map[string]Functionary{"agent": {KeyType: x509CAKeyType, KeyID: "agent", KeyVal: KeyVal{Certificate: otherCA.pem()}}},
+		},
+		"signature by another key": {
+			functionaries: map[string]Functionary{"agent": caFunctionary("agent", CertificateConstraints{})},
+			envelope:      newSignedTestEnvelope(t, testStatement, newTestKey(t), ""),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attestation := &Attestation{Envelope: envelope, Certificates: []*x509.Certificate{leaf}}
+			if test.envelope != nil {
+				attestation.Envelope = test.envelope
+			}
+
+			acceptedKeys, err := getCertificateKeys(log.NewEntry(log.New()), test.functionaries, attestation, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keyIDs := []string{}
+			for _, acceptedKey := range acceptedKeys {
+				keyIDs = append(keyIDs, acceptedKey.KeyID)
+			}
+			if !slices.Equal(keyIDs, test.want) {
+				t.Errorf("got key IDs %v, want %v", keyIDs, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCertificateChain(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	intermediateKey := newTestKey(t)
+	intermediate := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, ca.certificate, intermediateKey, ca.key)
+	intermediateCA := &testCA{certificate: intermediate, key: intermediateKey}
+
+	key := newTestKey(t)
+	leaf := intermediateCA.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "agent"}}, key)
+	expired := ca.issue(t, &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "agent"},
+		NotBefore: time.Now().Add(-2 * time.Hour),
+		NotAfter:  time.Now().Add(-time.Hour),
+	}, key)
+
+	functionary := Functionary{KeyType: x509CAKeyType, KeyID: "agent", KeyVal: KeyVal{Certificate: ca.pem()}}
+
+	tests := map[string]struct {
+		certificates []*x509.Certificate
+		functionary  Functionary
+		wantErr      string
+	}{
+		"through intermediate": {
+			certificates: []*x509.Certificate{leaf, intermediate},
+			functionary:  functionary,
+		},
+		"missing intermediate": {
+			certificates: []*x509.Certificate{leaf},
+			functionary:  functionary,
+			wantErr:      "certificate not valid",
+		},
+		"expired": {
+			certificates: []*x509.Certificate{expired},
+			functionary:  functionary,
+			wantErr:      "certificate not valid",
+		},
+		"no CA certificates": {
+			certificates: []*x509.Certificate{leaf, intermediate},
+			functionary:  Functionary{KeyType: x509CAKeyType, KeyID: "agent"},
+			wantErr:      "functionary has no CA certificates",
+		},
+		"unknown extended key usage": {
+			certificates: []*x509.Certificate{leaf, intermediate},
+			functionary:  Functionary{KeyType: x509CAKeyType, KeyID: "agent", KeyVal: KeyVal{Certificate: ca.pem()}, CertificateConstraints: CertificateConstraints{ExtKeyUsages: []string{"signing"}}},
+			wantErr:      "unknown extended key usage signing",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attestation := &Attestation{Envelope: newSignedTestEnvelope(t, testStatement, key, ""), Certificates: test.certificates}
+
+			err := verifyCertificateChain(test.functionary, attestation, nil, nil)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// A step that trusts one of several functionaries on the same CA must see
+// its claim however the functionaries are ordered.
+func TestVerifyCertificateFunctionaries(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	key := newTestKey(t)
+	leaf := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "release-agent"}}, key)
+
+	layout := &Layout{
+		Expires: time.Now().Add(time.Hour).Format(time.RFC3339),
+		Functionaries: map[string]Functionary{
+			"any-agent":     {KeyType: x509CAKeyType, KeyID: "any-agent", KeyVal: KeyVal{Certificate: ca.pem()}},
+			"release-agent": {KeyType: x509CAKeyType, KeyID: "release-agent", KeyVal: KeyVal{Certificate: ca.pem()}, CertificateConstraints: CertificateConstraints{CommonName: "release-agent"}},
+		},
+		DefaultArtifacts: defaultArtifactsNone,
+		Steps: []*Step{{
+			Name:               "build",
+			ExpectedPredicates: []ExpectedStepPredicates{{PredicateType: "https://example.com/test/v1", Functionaries: []string{"release-agent"}}},
+		}},
+	}
+	attestations := map[string]*Attestation{
+		"build.agent": {Envelope: newSignedTestEnvelope(t, testStatement, key, ""), Certificates: []*x509.Certificate{leaf}},
+	}
+
+	for range 20 {
+		if _, err := Verify(layout, attestations, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
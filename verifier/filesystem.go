@@ -0,0 +1,294 @@
+package verifier
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultAttestationPatterns select the files that are loaded as attestations
+// when no include patterns are given.
+var defaultAttestationPatterns = []string{"*.json", "*.jsonl"}
+
+// FileSystemSource loads attestations from a directory that's walked
+// recursively, a single file, a .tar, .tar.gz, or .zip archive, or `-` for a
+// single file or archive read from stdin. Attestations are named after the
+// path of their file relative to the directory or archive, without the
+// extension.
+//
+// Only .json and .jsonl files are loaded, unless include patterns are given
+// instead. Files and directories matching an exclude pattern are skipped.
+// Patterns match either a file's path, relative to the directory or archive,
+// or its base name.
+type FileSystemSource struct {
+	path    string
+	include []string
+	exclude []string
+
+	attestations map[string]*Attestation
+}
+
+func NewFileSystemSource(path string, include, exclude []string) *FileSystemSource {
+	return &FileSystemSource{path: path, include: include, exclude: exclude}
+}
+
+// List loads every attestation in the source. They're only read once.
+func (s *FileSystemSource) List(_ context.Context) (map[string]*Attestation, error) {
+	if s.attestations != nil {
+		return s.attestations, nil
+	}
+
+	attestations, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.attestations = attestations
+
+	return attestations, nil
+}
+
+// Fetch returns the attestations in the source with a subject that has the
+// digest.
+func (s *FileSystemSource) Fetch(ctx context.Context, digest string) (map[string]*Attestation, error) {
+	attestations, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := map[string]*Attestation{}
+	for name, attestation := range attestations {
+		if hasSubject(attestation, digest) {
+			matched[name] = attestation
+		}
+	}
+
+	return matched, nil
+}
+
+func (s *FileSystemSource) load() (map[string]*Attestation, error) {
+	attestations := map[string]*Attestation{}
+
+	if s.path == "-" {
+		contents, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		// A stream is named like a JSON Lines file, so that its
+		// attestations are always numbered.
+		return attestations, s.addContents(attestations, "stdin.jsonl", contents)
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		contents, err := os.ReadFile(s.path)
+		if err != nil {
+			return nil, err
+		}
+
+		return attestations, s.addContents(attestations, filepath.Base(s.path), contents)
+	}
+
+	err = filepath.WalkDir(s.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.path, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." && s.excludes(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !s.selects(rel) {
+			return nil
+		}
+
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		return addAttestations(attestations, rel, contents)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attestations, nil
+}
+
+func (s *FileSystemSource) selects(name string) bool {
+	include := s.include
+	if len(include) == 0 {
+		include = defaultAttestationPatterns
+	}
+
+	return matchesAny(include, name) && !s.excludes(name)
+}
+
+// excludes reports whether the file or directory, or any directory it's in,
+// is excluded.
+func (s *FileSystemSource) excludes(name string) bool {
+	for ; name != "." && name != "/"; name = path.Dir(name) {
+		if matchesAny(s.exclude, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+		if ok, err := path.Match(pattern, path.Base(name)); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addContents adds the attestations in contents, which may be an archive, in
+// which case its files are selected with the source's patterns.
+func (s *FileSystemSource) addContents(attestations map[string]*Attestation, name string, contents []byte) error {
+	switch {
+	case bytes.HasPrefix(contents, []byte("\x1f\x8b")):
+		reader, err := gzip.NewReader(bytes.NewReader(contents))
+		if err != nil {
+			return fmt.Errorf("unable to read archive %s: %w", name, err)
+		}
+
+		return s.addTarAttestations(attestations, name, reader)
+
+	case bytes.HasPrefix(contents, []byte("PK\x03\x04")):
+		return s.addZipAttestations(attestations, name, contents)
+
+	case len(contents) > 262 && string(contents[257:262]) == "ustar":
+		return s.addTarAttestations(attestations, name, bytes.NewReader(contents))
+
+	default:
+		return addAttestations(attestations, name, contents)
+	}
+}
+
+func (s *FileSystemSource) addTarAttestations(attestations map[string]*Attestation, name string, reader io.Reader) error {
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read archive %s: %w", name, err)
+		}
+
+		entryName := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if header.Typeflag != tar.TypeReg || !s.selects(entryName) {
+			continue
+		}
+
+		contents, err := io.ReadAll(archive)
+		if err != nil {
+			return fmt.Errorf("unable to read %s from archive %s: %w", entryName, name, err)
+		}
+
+		if err := addAttestations(attestations, entryName, contents); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *FileSystemSource) addZipAttestations(attestations map[string]*Attestation, name string, contents []byte) error {
+	archive, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return fmt.Errorf("unable to read archive %s: %w", name, err)
+	}
+
+	for _, file := range archive.File {
+		entryName := path.Clean(strings.TrimPrefix(file.Name, "./"))
+		if file.FileInfo().IsDir() || !s.selects(entryName) {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("unable to read %s from archive %s: %w", entryName, name, err)
+		}
+		entryContents, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("unable to read %s from archive %s: %w", entryName, name, err)
+		}
+
+		if err := addAttestations(attestations, entryName, entryContents); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addAttestations parses the attestations in a file and adds them under its
+// name, without the extension. Attestations in JSON Lines files, or in files
+// holding several, are numbered with getEntryName, so they're claims for the
+// same step as the file.
+func addAttestations(attestations map[string]*Attestation, name string, contents []byte) error {
+	fileAttestations, err := ParseAttestations(contents)
+	if err != nil {
+		return fmt.Errorf("unable to parse attestation %s: %w", name, err)
+	}
+
+	stem := strings.TrimSuffix(strings.TrimSuffix(name, ".jsonl"), ".json")
+	names := []string{stem}
+	if len(fileAttestations) != 1 || strings.HasSuffix(name, ".jsonl") {
+		names = make([]string, 0, len(fileAttestations))
+		for i := range fileAttestations {
+			names = append(names, getEntryName(stem, i))
+		}
+	}
+
+	for i, attestation := range fileAttestations {
+		if _, ok := attestations[names[i]]; ok {
+			return fmt.Errorf("found more than one attestation named %s", names[i])
+		}
+		attestations[names[i]] = attestation
+	}
+
+	return nil
+}
+
+// getEntryName names the attestation at index i of a file with the stem. The
+// step name is the stem up to its last dot, so the index is added to the last
+// segment, as in `build.fe1c6281-0`, or, if the stem has no dot and is the step
+// name itself, as a segment of its own, as in `build.0`.
+func getEntryName(stem string, i int) string {
+	if !strings.Contains(path.Base(stem), ".") {
+		return fmt.Sprintf("%s.%d", stem, i)
+	}
+
+	return fmt.Sprintf("%s-%d", stem, i)
+}
@@ -0,0 +1,42 @@
+package verifier
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAddAttestationsStepNames(t *testing.T) {
+	envelope := newTestEnvelope(t, `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "foo", "digest": {"sha256": "aa"}}], "predicateType": "https://example.com/test/v1", "predicate": {}}`)
+
+	tests := map[string]struct {
+		contents string
+		want     []string
+	}{
+		"build.fe1c6281.json":       {contents: envelope, want: []string{"build.fe1c6281"}},
+		"build.jsonl":               {contents: envelope + "\n" + envelope, want: []string{"build.0", "build.1"}},
+		"build.fe1c6281.jsonl":      {contents: envelope + "\n" + envelope, want: []string{"build.fe1c6281-0", "build.fe1c6281-1"}},
+		"build.fe1c6281.json array": {contents: "[" + envelope + "," + envelope + "]", want: []string{"build.fe1c6281-0", "build.fe1c6281-1"}},
+		"sub/build.fe1c6281.jsonl":  {contents: envelope, want: []string{"sub/build.fe1c6281-0"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attestations := map[string]*Attestation{}
+			if err := addAttestations(attestations, strings.TrimSuffix(name, " array"), []byte(test.contents)); err != nil {
+				t.Fatal(err)
+			}
+
+			names := slices.Sorted(maps.Keys(attestations))
+			if !slices.Equal(names, test.want) {
+				t.Fatalf("got names %v, want %v", names, test.want)
+			}
+			for _, attestationName := range names {
+				if stepName := getStepName(attestationName); stepName != "build" {
+					t.Errorf("got step name %q for %s, want build", stepName, attestationName)
+				}
+			}
+		})
+	}
+}
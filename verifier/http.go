@@ -0,0 +1,103 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxHTTPResponseSize bounds how much an attestation store can send back for
+// a single query.
+const maxHTTPResponseSize = 64 << 20
+
+// defaultHTTPTimeout bounds how long a query to an attestation store may take
+// when no client is given, so that a stalled store can't stall verification.
+const defaultHTTPTimeout = 30 * time.Second
+
+// HTTPSource fetches attestations from an attestation store over HTTP, like
+// Archivista or GUAC. A GET of the store's URL with a `digest` query parameter,
+// `<algorithm>:<hex>`, returns the attestations about the artifact with the
+// digest, and a GET without it returns every attestation. Responses hold a
+// single attestation, a JSON array of them, or JSON Lines, and a 404 means
+// there aren't any. Attestations are named after the digest of their
+// envelope, so they're usually bound to steps by predicate or content.
+type HTTPSource struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSource returns a source for the store at storeURL. If client is nil,
+// a client whose queries time out after 30 seconds is used.
+func NewHTTPSource(storeURL string, client *http.Client) *HTTPSource {
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+
+	return &HTTPSource{url: storeURL, client: client}
+}
+
+func (s *HTTPSource) List(ctx context.Context) (map[string]*Attestation, error) {
+	return s.get(ctx, url.Values{})
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context, digest string) (map[string]*Attestation, error) {
+	return s.get(ctx, url.Values{"digest": []string{digest}})
+}
+
+func (s *HTTPSource) get(ctx context.Context, query url.Values) (map[string]*Attestation, error) {
+	requestURL, err := url.Parse(s.url)
+	if err != nil {
+		return nil, err
+	}
+	values := requestURL.Query()
+	for key, value := range query {
+		values[key] = value
+	}
+	requestURL.RawQuery = values.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/jsonl, application/json")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	attestations := map[string]*Attestation{}
+	if response.StatusCode == http.StatusNotFound {
+		return attestations, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("attestation store returned %s", response.Status)
+	}
+
+	contents, err := io.ReadAll(io.LimitReader(response.Body, maxHTTPResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) > maxHTTPResponseSize {
+		return nil, fmt.Errorf("attestation store returned more than %d bytes", maxHTTPResponseSize)
+	}
+
+	responseAttestations, err := ParseAttestations(contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse attestations from store: %w", err)
+	}
+
+	for _, attestation := range responseAttestations {
+		name, err := getAttestationName(attestation)
+		if err != nil {
+			return nil, err
+		}
+		attestations[name] = attestation
+	}
+
+	return attestations, nil
+}
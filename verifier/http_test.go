@@ -0,0 +1,152 @@
+package verifier
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// testStore is an attestation store that serves unsigned envelopes about
+// digests as JSON Lines, and records the digests it was queried for.
+type testStore struct {
+	mu           sync.Mutex
+	attestations map[string][]string
+	queries      []string
+}
+
+func (s *testStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	digest := r.URL.Query().Get("digest")
+
+	s.mu.Lock()
+	s.queries = append(s.queries, digest)
+	s.mu.Unlock()
+
+	envelopes := []string{}
+	if digest == "" {
+		for _, digestEnvelopes := range s.attestations {
+			envelopes = append(envelopes, digestEnvelopes...)
+		}
+	} else {
+		envelopes = s.attestations[digest]
+	}
+	if len(envelopes) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Write([]byte(strings.Join(envelopes, "\n")))
+}
+
+func newTestEnvelope(t *testing.T, statement string) string {
+	t.Helper()
+
+	envelope, err := json.Marshal(&dsse.Envelope{
+		PayloadType: "application/vnd.in-toto+json",
+		Payload:     base64.StdEncoding.EncodeToString([]byte(statement)),
+		Signatures:  []dsse.Signature{{KeyID: "test", Sig: base64.StdEncoding.EncodeToString([]byte("unsigned"))}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(envelope)
+}
+
+func TestFetchAttestations(t *testing.T) {
+	build := newTestEnvelope(t, `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "app", "digest": {"sha256": "aaaa"}}], "predicateType": "https://slsa.dev/provenance/v1", "predicate": {"buildDefinition": {"buildType": "https://example.com/build", "resolvedDependencies": [{"name": "dep", "digest": {"sha256": "bbbb"}}]}}}`)
+	// Statements aren't verified before their materials are followed, so
+	// ones missing what their predicate type requires must not crash.
+	malformed := newTestEnvelope(t, `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "app", "digest": {"sha256": "aaaa"}}], "predicateType": "https://slsa.dev/provenance/v1", "predicate": {}}`)
+	dependency := newTestEnvelope(t, `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "dep", "digest": {"sha256": "bbbb"}}], "predicateType": "https://in-toto.io/attestation/link/v0.3", "predicate": {"name": "fetch"}}`)
+
+	store := &testStore{attestations: map[string][]string{
+		"sha256:aaaa": {build, malformed},
+		"sha256:bbbb": {dependency},
+	}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	subjects := []*attestationv1.ResourceDescriptor{{Name: "app", Digest: map[string]string{"sha256": "aaaa"}}}
+	attestations, err := FetchAttestations(context.Background(), NewHTTPSource(server.URL, nil), subjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attestations) != 3 {
+		t.Errorf("got %d attestations, want 3", len(attestations))
+	}
+	for _, digest := range []string{"sha256:aaaa", "sha256:bbbb"} {
+		if !slices.Contains(store.queries, digest) {
+			t.Errorf("store wasn't queried for %s", digest)
+		}
+	}
+}
+
+func TestFetchAttestationsAdapterPanic(t *testing.T) {
+	RegisterPredicateAdapter("https://example.com/panic/v1", PredicateAdapter{
+		Artifacts: func(*attestationv1.Statement) ([]*attestationv1.ResourceDescriptor, []*attestationv1.ResourceDescriptor, error) {
+			panic("malformed predicate")
+		},
+	})
+
+	store := &testStore{attestations: map[string][]string{
+		"sha256:aaaa": {newTestEnvelope(t, `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "app", "digest": {"sha256": "aaaa"}}], "predicateType": "https://example.com/panic/v1", "predicate": {}}`)},
+	}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	subjects := []*attestationv1.ResourceDescriptor{{Name: "app", Digest: map[string]string{"sha256": "aaaa"}}}
+	attestations, err := FetchAttestations(context.Background(), NewHTTPSource(server.URL, nil), subjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attestations) != 1 {
+		t.Errorf("got %d attestations, want 1", len(attestations))
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	store := &testStore{attestations: map[string][]string{
+		"sha256:aaaa": {newTestEnvelope(t, `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "app", "digest": {"sha256": "aaaa"}}], "predicateType": "https://example.com/test/v1", "predicate": {}}`)},
+		"sha256:bbbb": {newTestEnvelope(t, `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "dep", "digest": {"sha256": "bbbb"}}], "predicateType": "https://example.com/test/v1", "predicate": {}}`)},
+	}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	source := NewHTTPSource(server.URL, nil)
+
+	attestations, err := source.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attestations) != 2 {
+		t.Errorf("List: got %d attestations, want 2", len(attestations))
+	}
+
+	attestations, err = source.Fetch(context.Background(), "sha256:cccc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attestations) != 0 {
+		t.Errorf("Fetch of unknown digest: got %d attestations, want 0", len(attestations))
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	if _, err := NewHTTPSource(failing.URL, nil).Fetch(context.Background(), "sha256:aaaa"); err == nil {
+		t.Error("Fetch from failing store: got no error")
+	}
+}
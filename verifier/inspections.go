@@ -0,0 +1,164 @@
+package verifier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	linkPredicatev0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const linkPredicateType = "https://in-toto.io/attestation/link/v0.3"
+
+func verifyInspections(logger *log.Entry, env *cel.Env, inspections []*Inspection, workspace string, claims map[string]map[AttestationIdentifier]*attestationv1.Statement, layout *Layout, report *Report) error {
+	for _, inspection := range inspections {
+		inspectionReport := &InspectionReport{Name: inspection.Name}
+		report.Inspections = append(report.Inspections, inspectionReport)
+
+		logger.Infof("Running inspection '%s'...", inspection.Name)
+		statement, err := runInspection(inspection, workspace)
+		if err != nil {
+			err = fmt.Errorf("unable to run inspection %s: %w", inspection.Name, err)
+			inspectionReport.Errors = append(inspectionReport.Errors, err.Error())
+			return err
+		}
+
+		failedChecks := []error{}
+		if err := applyArtifactRules(logger, statement, inspection.ExpectedMaterials, inspection.ExpectedProducts, claims, layout, &inspectionReport.Rules); err != nil {
+			failedChecks = append(failedChecks, fmt.Errorf("inspection %s failed artifact rules: %w", inspection.Name, err))
+		}
+
+		input, err := getActivation(statement)
+		if err != nil {
+			return err
+		}
+
+		if err := applyAttributeRules(logger, env, input, inspection.ExpectedAttributes, &inspectionReport.Rules); err != nil {
+			failedChecks = append(failedChecks, fmt.Errorf("inspection %s failed attribute rules: %w", inspection.Name, err))
+		}
+
+		if len(failedChecks) > 0 {
+			for _, check := range failedChecks {
+				inspectionReport.Errors = append(inspectionReport.Errors, check.Error())
+			}
+			return errors.Join(failedChecks...)
+		}
+		inspectionReport.Accepted = true
+
+		// Later inspections may MATCH against the artifacts recorded here.
+		// Inspections are run by the verifier itself, so their results
+		// aren't attributed to any functionary.
+		claims[inspection.Name] = map[AttestationIdentifier]*attestationv1.Statement{
+			{PredicateType: statement.PredicateType}: statement,
+		}
+		logger.Info("Done.")
+	}
+
+	return nil
+}
+
+// runInspection executes the inspection's command in the workspace and
+// records the workspace before and after as the materials and products of a
+// link statement. If the inspection has no command, the result of an earlier
+// run is read from `<name>.json` in the workspace instead.
+func runInspection(inspection *Inspection, workspace string) (*attestationv1.Statement, error) {
+	if len(inspection.Command) == 0 {
+		statementBytes, err := os.ReadFile(filepath.Join(workspace, fmt.Sprintf("%s.json", inspection.Name)))
+		if err != nil {
+			return nil, err
+		}
+
+		statement := &attestationv1.Statement{}
+		if err := protojson.Unmarshal(statementBytes, statement); err != nil {
+			return nil, err
+		}
+
+		return statement, nil
+	}
+
+	command := strings.Fields(inspection.Command)
+
+	materials, err := recordWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	byproducts, err := in_toto.RunCommand(command, workspace)
+	if err != nil {
+		return nil, err
+	}
+	if returnValue := byproducts["return-value"]; returnValue != float64(0) {
+		return nil, fmt.Errorf("command `%s` returned %v: %s", inspection.Command, returnValue, byproducts["stderr"])
+	}
+
+	products, err := recordWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	byproductsStruct, err := structpb.NewStruct(byproducts)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &linkPredicatev0.Link{
+		Name:       inspection.Name,
+		Command:    command,
+		Materials:  materials,
+		Byproducts: byproductsStruct,
+	}
+	linkBytes, err := protojson.Marshal(link)
+	if err != nil {
+		return nil, err
+	}
+
+	predicate := &structpb.Struct{}
+	if err := protojson.Unmarshal(linkBytes, predicate); err != nil {
+		return nil, err
+	}
+
+	return &attestationv1.Statement{
+		Type:          attestationv1.StatementTypeUri,
+		Subject:       products,
+		PredicateType: linkPredicateType,
+		Predicate:     predicate,
+	}, nil
+}
+
+func recordWorkspace(workspace string) ([]*attestationv1.ResourceDescriptor, error) {
+	workspace = filepath.Clean(workspace)
+	lStripPaths := []string{}
+	if workspace != "." {
+		lStripPaths = append(lStripPaths, workspace+string(filepath.Separator))
+	}
+
+	artifacts, err := in_toto.RecordArtifacts([]string{workspace}, []string{"sha256"}, nil, lStripPaths, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	descriptors := make([]*attestationv1.ResourceDescriptor, 0, len(names))
+	for _, name := range names {
+		descriptors = append(descriptors, &attestationv1.ResourceDescriptor{
+			Name:   name,
+			Digest: artifacts[name],
+		})
+	}
+
+	return descriptors, nil
+}
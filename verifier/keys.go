@@ -0,0 +1,428 @@
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/secure-systems-lab/go-securesystemslib/cjson"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/secure-systems-lab/go-securesystemslib/signerverifier"
+)
+
+var errUnsupportedKeyType = errors.New("unsupported key type")
+
+// LoadVerifier loads a PEM encoded public key from path.
+func LoadVerifier(path string) (dsse.Verifier, error) {
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := signerverifier.LoadKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load key %s: %w", path, err)
+	}
+
+	// securesystemslib assigns every ECDSA key the P-256 scheme, regardless
+	// of its curve.
+	if key.KeyType == "ecdsa" {
+		public, err := parsePublicKey(key.KeyType, key.KeyVal.Public)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load key %s: %w", path, err)
+		}
+
+		for name, scheme := range signatureSchemes {
+			if scheme.curve == public.(*ecdsa.PublicKey).Curve {
+				key.Scheme = name
+			}
+		}
+	}
+
+	return newVerifier(key)
+}
+
+// LoadSigner loads a PEM encoded private key from path.
+func LoadSigner(path string) (dsse.Signer, error) {
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := signerverifier.LoadKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load key %s: %w", path, err)
+	}
+
+	if len(key.KeyVal.Private) == 0 {
+		return nil, fmt.Errorf("key %s is not a private key", path)
+	}
+
+	switch key.KeyType {
+	case "rsa":
+		return signerverifier.NewRSAPSSSignerVerifierFromSSLibKey(key)
+	case "ecdsa":
+		return signerverifier.NewECDSASignerVerifierFromSSLibKey(key)
+	case "ed25519":
+		return signerverifier.NewED25519SignerVerifierFromSSLibKey(key)
+	default:
+		return nil, fmt.Errorf("%w %s", errUnsupportedKeyType, key.KeyType)
+	}
+}
+
+// signatureSchemes maps each supported signature scheme to the key type it's
+// used with and the hash it signs.
+var signatureSchemes = map[string]struct {
+	keyType string
+	hash    crypto.Hash
+	pss     bool
+	curve   elliptic.Curve
+}{
+	"rsassa-pss-sha256":   {keyType: "rsa", hash: crypto.SHA256, pss: true},
+	"rsassa-pss-sha384":   {keyType: "rsa", hash: crypto.SHA384, pss: true},
+	"rsassa-pss-sha512":   {keyType: "rsa", hash: crypto.SHA512, pss: true},
+	"rsa-pkcs1v15-sha256": {keyType: "rsa", hash: crypto.SHA256},
+	"rsa-pkcs1v15-sha384": {keyType: "rsa", hash: crypto.SHA384},
+	"rsa-pkcs1v15-sha512": {keyType: "rsa", hash: crypto.SHA512},
+	"ecdsa-sha2-nistp256": {keyType: "ecdsa", hash: crypto.SHA256, curve: elliptic.P256()},
+	"ecdsa-sha2-nistp384": {keyType: "ecdsa", hash: crypto.SHA384, curve: elliptic.P384()},
+	"ecdsa-sha2-nistp521": {keyType: "ecdsa", hash: crypto.SHA512, curve: elliptic.P521()},
+	"ed25519":             {keyType: "ed25519"},
+}
+
+// keyVerifier verifies signatures made with a public key under a single
+// signature scheme.
+type keyVerifier struct {
+	keyID  string
+	scheme string
+	public crypto.PublicKey
+}
+
+// jwkSchemes maps JWK signature algorithms to signature schemes.
+var jwkSchemes = map[string]string{
+	"PS256": "rsassa-pss-sha256",
+	"PS384": "rsassa-pss-sha384",
+	"PS512": "rsassa-pss-sha512",
+	"RS256": "rsa-pkcs1v15-sha256",
+	"RS384": "rsa-pkcs1v15-sha384",
+	"RS512": "rsa-pkcs1v15-sha512",
+	"ES256": "ecdsa-sha2-nistp256",
+	"ES384": "ecdsa-sha2-nistp384",
+	"ES512": "ecdsa-sha2-nistp521",
+	"EdDSA": "ed25519",
+}
+
+// loadFunctionaries returns the functionaries with their public keys in
+// securesystemslib's serialization, and their key IDs computed from them.
+// Certificate-based functionaries are returned as is. Their key ID is what
+// their claims are attributed to, so it must be declared, and mustn't be
+// shared with any other functionary.
+func loadFunctionaries(functionaries map[string]Functionary) (map[string]Functionary, error) {
+	loaded := make(map[string]Functionary, len(functionaries))
+	for name, functionary := range functionaries {
+		if isCertificateKeyType(functionary.KeyType) {
+			if len(functionary.KeyID) == 0 {
+				return nil, fmt.Errorf("unable to load functionary %s: %s functionaries must declare a keyID", name, functionary.KeyType)
+			}
+
+			loaded[name] = functionary
+			continue
+		}
+
+		if functionary.KeyType == openPGPKeyType {
+			entity, err := loadOpenPGPKey(functionary.KeyVal.Public)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load functionary %s: %w", name, err)
+			}
+
+			keyID := openPGPKeyID(entity)
+			if len(functionary.KeyID) > 0 && !strings.EqualFold(functionary.KeyID, keyID) {
+				return nil, fmt.Errorf("unable to load functionary %s: declared keyID %s does not match fingerprint %s", name, functionary.KeyID, keyID)
+			}
+
+			if len(functionary.KeyID) == 0 {
+				functionary.KeyID = keyID
+			}
+			loaded[name] = functionary
+			continue
+		}
+
+		key, err := loadFunctionaryKey(functionary)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load functionary %s: %w", name, err)
+		}
+
+		functionary.KeyIDHashAlgorithms = key.KeyIDHashAlgorithms
+		functionary.KeyType = key.KeyType
+		functionary.KeyVal.Public = key.KeyVal.Public
+		functionary.Scheme = key.Scheme
+		functionary.KeyID = key.KeyID
+		loaded[name] = functionary
+	}
+
+	names := slices.Sorted(maps.Keys(loaded))
+	for _, name := range names {
+		if !isCertificateKeyType(loaded[name].KeyType) {
+			continue
+		}
+
+		for _, other := range names {
+			if other != name && loaded[other].KeyID == loaded[name].KeyID {
+				return nil, fmt.Errorf("unable to load functionary %s: keyID %s is also the keyID of functionary %s", name, loaded[name].KeyID, other)
+			}
+		}
+	}
+
+	return loaded, nil
+}
+
+// loadFunctionaryKey accepts the functionary's public key in
+// securesystemslib's serialization, as a PEM encoded SubjectPublicKeyInfo, or
+// as a JWK. The key type and scheme default to those of the key, and the key
+// ID is computed the way securesystemslib does. A declared key ID must match
+// the computed one, so that claims can't be attributed to the wrong
+// functionary.
+func loadFunctionaryKey(functionary Functionary) (*signerverifier.SSLibKey, error) {
+	public := strings.TrimSpace(functionary.KeyVal.Public)
+	scheme := functionary.Scheme
+
+	var publicKey crypto.PublicKey
+	switch {
+	case len(public) == 0:
+		return nil, fmt.Errorf("no public key")
+	case strings.HasPrefix(public, "{"):
+		jwk := &jose.JSONWebKey{}
+		if err := jwk.UnmarshalJSON([]byte(public)); err != nil {
+			return nil, fmt.Errorf("unable to parse JWK: %w", err)
+		}
+		if !jwk.IsPublic() {
+			return nil, fmt.Errorf("JWK is not a public key")
+		}
+		publicKey = jwk.Key
+
+		if len(scheme) == 0 {
+			scheme = jwkSchemes[jwk.Algorithm]
+		}
+	case strings.HasPrefix(public, "-----BEGIN"):
+		block, _ := pem.Decode([]byte(public))
+		if block == nil {
+			return nil, fmt.Errorf("unable to decode PEM public key")
+		}
+
+		var err error
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		publicBytes, err := hex.DecodeString(public)
+		if err != nil || len(publicBytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("public key is not a PEM, JWK, or hex encoded ed25519 key")
+		}
+		publicKey = ed25519.PublicKey(publicBytes)
+	}
+
+	key := &signerverifier.SSLibKey{
+		KeyIDHashAlgorithms: functionary.KeyIDHashAlgorithms,
+		KeyType:             functionary.KeyType,
+		Scheme:              scheme,
+	}
+	if len(key.KeyIDHashAlgorithms) == 0 {
+		key.KeyIDHashAlgorithms = signerverifier.KeyIDHashAlgorithms
+	}
+
+	var keyType, defaultScheme string
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		keyType, defaultScheme = "rsa", "rsassa-pss-sha256"
+	case *ecdsa.PublicKey:
+		keyType = "ecdsa"
+		for name, s := range signatureSchemes {
+			if s.curve == k.Curve {
+				defaultScheme = name
+			}
+		}
+	case ed25519.PublicKey:
+		keyType, defaultScheme = "ed25519", "ed25519"
+		key.KeyVal.Public = hex.EncodeToString(k)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	if len(key.KeyVal.Public) == 0 {
+		publicBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		key.KeyVal.Public = strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes})))
+	}
+
+	if len(key.KeyType) == 0 {
+		key.KeyType = keyType
+	} else if normalizeKeyType(key.KeyType) != keyType {
+		return nil, fmt.Errorf("declared keyType %s does not match %s public key", key.KeyType, keyType)
+	}
+	if len(key.Scheme) == 0 {
+		key.Scheme = defaultScheme
+	}
+
+	keyID, err := computeKeyID(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(functionary.KeyID) > 0 && functionary.KeyID != keyID {
+		return nil, fmt.Errorf("declared keyID %s does not match computed keyID %s", functionary.KeyID, keyID)
+	}
+	key.KeyID = keyID
+
+	return key, nil
+}
+
+// computeKeyID computes a key's ID as securesystemslib does, from the SHA-256
+// digest of its canonical JSON serialization.
+func computeKeyID(key *signerverifier.SSLibKey) (string, error) {
+	canonical, err := cjson.EncodeCanonical(map[string]any{
+		"keytype":               key.KeyType,
+		"scheme":                key.Scheme,
+		"keyid_hash_algorithms": key.KeyIDHashAlgorithms,
+		"keyval": map[string]string{
+			"public": key.KeyVal.Public,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(canonical)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// newVerifier returns a verifier for the key's signature scheme. The key type
+// must be one the scheme is used with.
+func newVerifier(key *signerverifier.SSLibKey) (dsse.Verifier, error) {
+	scheme, ok := signatureSchemes[key.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature scheme %q for key %s", key.Scheme, key.KeyID)
+	}
+
+	keyType := normalizeKeyType(key.KeyType)
+	if keyType != scheme.keyType {
+		return nil, fmt.Errorf("%w %q for scheme %s of key %s", errUnsupportedKeyType, key.KeyType, key.Scheme, key.KeyID)
+	}
+
+	public, err := parsePublicKey(keyType, key.KeyVal.Public)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load key %s: %w", key.KeyID, err)
+	}
+
+	if ecdsaKey, ok := public.(*ecdsa.PublicKey); ok && ecdsaKey.Curve != scheme.curve {
+		return nil, fmt.Errorf("key %s uses curve %s, not the one for scheme %s", key.KeyID, ecdsaKey.Curve.Params().Name, key.Scheme)
+	}
+
+	return &keyVerifier{keyID: key.KeyID, scheme: key.Scheme, public: public}, nil
+}
+
+// normalizeKeyType maps older securesystemslib ECDSA key types, which are
+// named after their scheme, to the current one.
+func normalizeKeyType(keyType string) string {
+	if keyType == "ecdsa-sha2-nistp256" || keyType == "ecdsa-sha2-nistp384" {
+		return "ecdsa"
+	}
+
+	return keyType
+}
+
+func parsePublicKey(keyType, public string) (crypto.PublicKey, error) {
+	if len(public) == 0 {
+		return nil, fmt.Errorf("no public key")
+	}
+
+	if keyType == "ed25519" {
+		publicBytes, err := hex.DecodeString(public)
+		if err != nil {
+			return nil, err
+		}
+		if len(publicBytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key length %d", len(publicBytes))
+		}
+
+		return ed25519.PublicKey(publicBytes), nil
+	}
+
+	block, _ := pem.Decode([]byte(public))
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		if keyType == "rsa" {
+			return publicKey, nil
+		}
+	case *ecdsa.PublicKey:
+		if keyType == "ecdsa" {
+			return publicKey, nil
+		}
+	}
+
+	return nil, fmt.Errorf("public key is not a %s key", keyType)
+}
+
+func (v *keyVerifier) Verify(_ context.Context, data, sig []byte) error {
+	scheme := signatureSchemes[v.scheme]
+
+	digest := data
+	if scheme.hash != 0 {
+		hasher := scheme.hash.New()
+		hasher.Write(data)
+		digest = hasher.Sum(nil)
+	}
+
+	switch public := v.public.(type) {
+	case *rsa.PublicKey:
+		if scheme.pss {
+			return rsa.VerifyPSS(public, scheme.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: scheme.hash})
+		}
+		return rsa.VerifyPKCS1v15(public, scheme.hash, digest, sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(public, digest, sig) {
+			return signerverifier.ErrSignatureVerificationFailed
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(public, digest, sig) {
+			return signerverifier.ErrSignatureVerificationFailed
+		}
+	default:
+		return fmt.Errorf("%w %T for key %s", errUnsupportedKeyType, v.public, v.keyID)
+	}
+
+	return nil
+}
+
+func (v *keyVerifier) KeyID() (string, error) {
+	return v.keyID, nil
+}
+
+func (v *keyVerifier) Public() crypto.PublicKey {
+	return v.public
+}
@@ -0,0 +1,259 @@
+package verifier
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/secure-systems-lab/go-securesystemslib/cjson"
+	"github.com/secure-systems-lab/go-securesystemslib/signerverifier"
+)
+
+func TestLoadFunctionariesCertificateKeyIDs(t *testing.T) {
+	keyFunctionary := Functionary{KeyVal: KeyVal{Public: "7345b83c121ea0d9ffc3b38d69958718b8435e8cb0552f889d695586693e1b89"}}
+	keyID := "fe1c6281c5ff13e35286cc67e5a1fb3e6575b840a6c39ca4267d3805eb17288a"
+
+	tests := map[string]struct {
+		functionaries map[string]Functionary
+		wantErr       string
+	}{
+		"declared": {
+			functionaries: map[string]Functionary{
+				"agents":  {KeyType: x509CAKeyType, KeyID: "agents"},
+				"release": {KeyType: sigstoreKeyType, KeyID: "release"},
+				"alice":   keyFunctionary,
+			},
+		},
+		"missing": {
+			functionaries: map[string]Functionary{"agents": {KeyType: x509CAKeyType}},
+			wantErr:       "x509-ca functionaries must declare a keyID",
+		},
+		"shared with a certificate functionary": {
+			functionaries: map[string]Functionary{
+				"agents":  {KeyType: x509CAKeyType, KeyID: "builders"},
+				"release": {KeyType: sigstoreKeyType, KeyID: "builders"},
+			},
+			wantErr: "keyID builders is also the keyID of functionary release",
+		},
+		"shared with a key functionary": {
+			functionaries: map[string]Functionary{
+				"agents": {KeyType: x509CAKeyType, KeyID: keyID},
+				"alice":  keyFunctionary,
+			},
+			wantErr: "is also the keyID of functionary alice",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadFunctionaries(test.functionaries)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// The securesystemslib test keys and links in testdata were made by
+// securesystemslib, along with the key IDs it computed for them.
+func TestComputeKeyID(t *testing.T) {
+	tests := map[string]struct {
+		file  string
+		keyID string
+	}{
+		"rsa pem":                  {file: "rsa-test-key.pub", keyID: "4e8d20af09fcaed6c388a186427f94a5f7ff5591ec295f4aab2cff49ffe39e9b"},
+		"ecdsa securesystemslib":   {file: "ecdsa-test-key.pub", keyID: "98adf38602c48c5479e9a991ee3f8cbf541ee4f985e00f7a5fc4148d9a45b704"},
+		"ecdsa pem":                {file: "ecdsa-test-key-pem.pub", keyID: "98adf38602c48c5479e9a991ee3f8cbf541ee4f985e00f7a5fc4148d9a45b704"},
+		"ed25519 securesystemslib": {file: "ed25519-test-key.pub", keyID: "52e3b8e73279d6ebdd62a5016e2725ff284f569665eb92ccb145d83817a02997"},
+		"ed25519 pem":              {file: "ed25519-test-key-pem.pub", keyID: "52e3b8e73279d6ebdd62a5016e2725ff284f569665eb92ccb145d83817a02997"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			functionary := readTestFunctionary(t, test.file, "")
+
+			if strings.HasPrefix(strings.TrimSpace(functionary.KeyVal.Public), "{") {
+				sslibKey := &signerverifier.SSLibKey{}
+				if err := json.Unmarshal([]byte(functionary.KeyVal.Public), sslibKey); err != nil {
+					t.Fatal(err)
+				}
+
+				keyID, err := computeKeyID(sslibKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if keyID != test.keyID {
+					t.Errorf("computeKeyID: got %s, want %s", keyID, test.keyID)
+				}
+
+				functionary = Functionary{
+					KeyType:             sslibKey.KeyType,
+					Scheme:              sslibKey.Scheme,
+					KeyIDHashAlgorithms: sslibKey.KeyIDHashAlgorithms,
+					KeyVal:              KeyVal{Public: sslibKey.KeyVal.Public},
+				}
+			}
+
+			key, err := loadFunctionaryKey(functionary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.KeyID != test.keyID {
+				t.Errorf("loadFunctionaryKey: got %s, want %s", key.KeyID, test.keyID)
+			}
+		})
+	}
+}
+
+func TestKeyVerifierSecuresystemslib(t *testing.T) {
+	tests := map[string]struct {
+		key  string
+		link string
+	}{
+		"rsassa-pss-sha256":   {key: "rsa-test-key.pub", link: "test-rsa.4e8d20af.link"},
+		"ecdsa-sha2-nistp256": {key: "ecdsa-test-key-pem.pub", link: "test-ecdsa.98adf386.link"},
+		"ed25519":             {key: "ed25519-test-key-pem.pub", link: "test-ed25519.52e3b8e7.link"},
+	}
+
+	for scheme, test := range tests {
+		t.Run(scheme, func(t *testing.T) {
+			linkBytes, err := os.ReadFile(filepath.Join("testdata", test.link))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			link := struct {
+				Signatures []struct {
+					Sig string `json:"sig"`
+				} `json:"signatures"`
+				Signed any `json:"signed"`
+			}{}
+			if err := json.Unmarshal(linkBytes, &link); err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := cjson.EncodeCanonical(link.Signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := hex.DecodeString(link.Signatures[0].Sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verifyKnownAnswer(t, readTestFunctionary(t, test.key, scheme), signed, sig)
+		})
+	}
+}
+
+// The keys and signatures over testdata/message for each scheme were made
+// with OpenSSL.
+func TestKeyVerifierSchemes(t *testing.T) {
+	tests := map[string]string{
+		"rsassa-pss-sha256":   "rsa.pub",
+		"rsassa-pss-sha384":   "rsa.pub",
+		"rsassa-pss-sha512":   "rsa.pub",
+		"rsa-pkcs1v15-sha256": "rsa.pub",
+		"rsa-pkcs1v15-sha384": "rsa.pub",
+		"rsa-pkcs1v15-sha512": "rsa.pub",
+		"ecdsa-sha2-nistp256": "ecdsa-p256.pub",
+		"ecdsa-sha2-nistp384": "ecdsa-p384.pub",
+		"ecdsa-sha2-nistp521": "ecdsa-p521.pub",
+		"ed25519":             "ed25519.pub",
+	}
+
+	if len(tests) != len(signatureSchemes) {
+		t.Fatalf("got %d schemes under test, want all %d", len(tests), len(signatureSchemes))
+	}
+
+	message, err := os.ReadFile(filepath.Join("testdata", "message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for scheme, keyFile := range tests {
+		t.Run(scheme, func(t *testing.T) {
+			sig, err := os.ReadFile(filepath.Join("testdata", scheme+".sig"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verifyKnownAnswer(t, readTestFunctionary(t, keyFile, scheme), message, sig)
+		})
+	}
+}
+
+func TestKeyVerifierUnsupportedKey(t *testing.T) {
+	verifier := &keyVerifier{keyID: "test", scheme: "ed25519", public: "not a key"}
+	if err := verifier.Verify(context.Background(), []byte("data"), []byte("sig")); !errors.Is(err, errUnsupportedKeyType) {
+		t.Errorf("got error %v, want %v", err, errUnsupportedKeyType)
+	}
+}
+
+func TestNewVerifierSchemeMismatch(t *testing.T) {
+	tests := map[string]string{
+		"rsa key with ecdsa scheme":     "rsa.pub",
+		"p384 key with p256 scheme":     "ecdsa-p384.pub",
+		"ed25519 key with ecdsa scheme": "ed25519.pub",
+	}
+
+	for name, keyFile := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := loadFunctionaryKey(readTestFunctionary(t, keyFile, ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			key.Scheme = "ecdsa-sha2-nistp256"
+			if _, err := newVerifier(key); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func readTestFunctionary(t *testing.T, file, scheme string) Functionary {
+	t.Helper()
+
+	public, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Functionary{Scheme: scheme, KeyVal: KeyVal{Public: string(public)}}
+}
+
+// verifyKnownAnswer checks that the functionary's key verifies sig over
+// data, but not over anything else.
+func verifyKnownAnswer(t *testing.T, functionary Functionary, data, sig []byte) {
+	t.Helper()
+
+	key, err := loadFunctionaryKey(functionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := newVerifier(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifier.Verify(context.Background(), data, sig); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := verifier.Verify(context.Background(), append(slices.Clone(data), '!'), sig); err == nil {
+		t.Error("signature over other data: got no error")
+	}
+}
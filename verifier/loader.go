@@ -0,0 +1,152 @@
+package verifier
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+const sigstoreBundleMediaTypePrefix = "application/vnd.dev.sigstore.bundle"
+
+// ParseAttestation parses either a bare DSSE envelope or a Sigstore bundle
+// that wraps one. The verification material carried by a bundle is kept
+// alongside the envelope so that its signature can be verified against
+// certificate-based functionaries.
+func ParseAttestation(contents []byte) (*Attestation, error) {
+	header := struct {
+		MediaType string `json:"mediaType"`
+	}{}
+	if err := json.Unmarshal(contents, &header); err != nil {
+		return nil, err
+	}
+
+	parse := parseEnvelope
+	if strings.HasPrefix(header.MediaType, sigstoreBundleMediaTypePrefix) {
+		parse = parseBundle
+	}
+
+	attestation, err := parse(contents)
+	if err != nil {
+		return nil, err
+	}
+	attestation.Raw = contents
+
+	return attestation, nil
+}
+
+// ParseAttestations parses every attestation in a file, which may hold a
+// single attestation, a JSON array of them, or JSON Lines with one per line,
+// like an in-toto attestation bundle.
+func ParseAttestations(contents []byte) ([]*Attestation, error) {
+	entries := []json.RawMessage{}
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	for {
+		entry := json.RawMessage{}
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 1 && bytes.HasPrefix(bytes.TrimSpace(entries[0]), []byte("[")) {
+		entries = []json.RawMessage{}
+		if err := json.Unmarshal(contents, &entries); err != nil {
+			return nil, err
+		}
+	} else if len(entries) == 1 {
+		// A file holding a single attestation is kept as is, so that it's
+		// identified by the digest of the file.
+		entries[0] = contents
+	}
+
+	attestations := make([]*Attestation, 0, len(entries))
+	for i, entry := range entries {
+		attestation, err := ParseAttestation(entry)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		attestations = append(attestations, attestation)
+	}
+
+	return attestations, nil
+}
+
+// parseEnvelope parses a bare DSSE envelope. An envelope with a single
+// signature may carry the PEM encoded chain of the certificate that made it,
+// leaf first, in the signature's `cert` field.
+func parseEnvelope(contents []byte) (*Attestation, error) {
+	envelope := &dsse.Envelope{}
+	if err := json.Unmarshal(contents, envelope); err != nil {
+		return nil, err
+	}
+
+	signatures := struct {
+		Signatures []struct {
+			Cert string `json:"cert"`
+		} `json:"signatures"`
+	}{}
+	if err := json.Unmarshal(contents, &signatures); err != nil {
+		return nil, err
+	}
+
+	certificates := []*x509.Certificate{}
+	if len(signatures.Signatures) == 1 && len(signatures.Signatures[0].Cert) > 0 {
+		var err error
+		certificates, err = cryptoutils.UnmarshalCertificatesFromPEM([]byte(signatures.Signatures[0].Cert))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse signature certificate: %w", err)
+		}
+	}
+
+	return &Attestation{Envelope: envelope, Certificates: certificates}, nil
+}
+
+func parseBundle(contents []byte) (*Attestation, error) {
+	b := &bundle.Bundle{}
+	if err := b.UnmarshalJSON(contents); err != nil {
+		return nil, err
+	}
+
+	envelope, err := b.Envelope()
+	if err != nil {
+		return nil, fmt.Errorf("bundle does not contain a DSSE envelope: %w", err)
+	}
+
+	verificationContent, err := b.VerificationContent()
+	if err != nil {
+		return nil, err
+	}
+
+	// Bundles signed with a public key only carry a hint for it, in which
+	// case the envelope is verified against key functionaries as usual.
+	certificates := []*x509.Certificate{}
+	if certificate := verificationContent.Certificate(); certificate != nil {
+		certificates = append(certificates, certificate)
+	}
+
+	timestamps, err := b.Timestamps()
+	if err != nil {
+		return nil, err
+	}
+
+	tlogEntries, err := b.TlogEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Attestation{
+		Envelope:     envelope.RawEnvelope(),
+		Certificates: certificates,
+		Timestamps:   timestamps,
+		TlogEntries:  tlogEntries,
+	}, nil
+}
@@ -1,11 +1,20 @@
 package verifier
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"gopkg.in/yaml.v3"
 )
 
+// LayoutPayloadType is the DSSE payload type of signed layouts. The payload
+// is the layout's YAML document.
+const LayoutPayloadType = "application/vnd.in-toto.layout+yaml"
+
 // copied from go-sslib to use yaml tags
 type Functionary struct {
 	KeyIDHashAlgorithms []string `yaml:"keyIDHashAlgorithms"`
@@ -13,10 +22,36 @@ type Functionary struct {
 	KeyVal              KeyVal   `yaml:"keyVal"`
 	Scheme              string   `yaml:"scheme"`
 	KeyID               string   `yaml:"keyID"`
+
+	// Owner identifies who holds the key. Thresholds count distinct owners,
+	// so one owner holding several keys only counts once. Each key is its
+	// own owner by default.
+	Owner string `yaml:"owner"`
+
+	// CertificateConstraints restricts which certificates issued by an
+	// x509-ca functionary's CAs are accepted.
+	CertificateConstraints CertificateConstraints `yaml:"certificateConstraints"`
+
+	// RequireTransparencyLog rejects this functionary's signatures unless
+	// they're recorded in a transparency log from the trusted root.
+	RequireTransparencyLog bool `yaml:"requireTransparencyLog"`
 }
 
 type KeyVal struct {
-	Public string `yaml:"public"`
+	Public      string `yaml:"public"`
+	PublicFile  string `yaml:"publicFile"`
+	Certificate string `yaml:"certificate"`
+	Identity    string `yaml:"identity"`
+	Issuer      string `yaml:"issuer"`
+}
+
+type CertificateConstraints struct {
+	CommonName    string   `yaml:"commonName"`
+	Organizations []string `yaml:"organizations"`
+	DNSNames      []string `yaml:"dnsNames"`
+	Emails        []string `yaml:"emails"`
+	URIs          []string `yaml:"uris"`
+	ExtKeyUsages  []string `yaml:"extKeyUsages"`
 }
 
 type Constraint struct {
@@ -26,25 +61,57 @@ type Constraint struct {
 	Debug          string `yaml:"debug"`
 }
 
+// Role names a group of functionaries that steps and subjects can refer to
+// together. Members are functionaries' names in the layout or their key IDs.
+// If the role has a threshold, at least that many of its members must make
+// accepted claims wherever the role is referenced.
+type Role struct {
+	Functionaries []string `yaml:"functionaries"`
+	Threshold     int      `yaml:"threshold"`
+}
+
+// Agreement requires the claims counted towards a threshold to describe the
+// same thing: the same subjects and materials, and the same values for each
+// of the CEL expressions in Fields.
+type Agreement struct {
+	Fields []string `yaml:"fields"`
+}
+
 type ExpectedStepPredicates struct {
 	PredicateType      string       `yaml:"predicateType"`
 	ExpectedAttributes []Constraint `yaml:"expectedAttributes"`
 	Functionaries      []string     `yaml:"functionaries"`
+	Roles              []string     `yaml:"roles"`
 	Threshold          int          `yaml:"threshold"`
+	Agreement          *Agreement   `yaml:"agreement"`
+}
+
+// StepBinding selects the attestations that are claims for a step when the
+// layout binds steps by content. An attestation must meet every condition
+// that's set: have the predicate type, have a subject matching each of the
+// subject patterns, and satisfy the CEL selector.
+type StepBinding struct {
+	Selector      string   `yaml:"selector"`
+	PredicateType string   `yaml:"predicateType"`
+	Subjects      []string `yaml:"subjects"`
 }
 
 type Step struct {
-	Name               string                   `yaml:"name"`
-	Command            string                   `yaml:"command"`
-	ExpectedMaterials  []string                 `yaml:"expectedMaterials"`
-	ExpectedProducts   []string                 `yaml:"expectedProducts"`
-	ExpectedPredicates []ExpectedStepPredicates `yaml:"expectedPredicates"`
+	Name                  string                   `yaml:"name"`
+	Command               string                   `yaml:"command"`
+	CommandMatch          string                   `yaml:"commandMatch"`
+	WarnOnCommandMismatch bool                     `yaml:"warnOnCommandMismatch"`
+	ExpectedMaterials     []string                 `yaml:"expectedMaterials"`
+	ExpectedProducts      []string                 `yaml:"expectedProducts"`
+	ExpectedPredicates    []ExpectedStepPredicates `yaml:"expectedPredicates"`
+	Binding               *StepBinding             `yaml:"binding"`
 }
 
 type ExpectedSubjectPredicates struct {
 	PredicateType      string       `yaml:"predicateType"`
 	ExpectedAttributes []Constraint `yaml:"expectedAttributes"`
 	Functionaries      []string     `yaml:"functionaries"`
+	Roles              []string     `yaml:"roles"`
 	Threshold          int          `yaml:"threshold"`
 }
 
@@ -63,11 +130,16 @@ type Inspection struct {
 }
 
 type Layout struct {
-	Expires       string                 `yaml:"expires"`
-	Functionaries map[string]Functionary `yaml:"functionaries"`
-	Steps         []*Step                `yaml:"steps"`
-	Subjects      []*Subject             `yaml:"subjects"`
-	Inspections   []*Inspection          `yaml:"inspections"`
+	Expires          string                 `yaml:"expires"`
+	VerifiedLevels   []string               `yaml:"verifiedLevels"`
+	Functionaries    map[string]Functionary `yaml:"functionaries"`
+	Roles            map[string]Role        `yaml:"roles"`
+	MatchPolicy      string                 `yaml:"matchPolicy"`
+	StepBinding      string                 `yaml:"stepBinding"`
+	DefaultArtifacts string                 `yaml:"defaultArtifacts"`
+	Steps            []*Step                `yaml:"steps"`
+	Subjects         []*Subject             `yaml:"subjects"`
+	Inspections      []*Inspection          `yaml:"inspections"`
 }
 
 func LoadLayout(path string) (*Layout, error) {
@@ -76,11 +148,81 @@ func LoadLayout(path string) (*Layout, error) {
 		return nil, err
 	}
 
+	return parseLayout(layoutBytes, filepath.Dir(path), false)
+}
+
+// LoadSignedLayout loads a layout wrapped in a DSSE envelope. The envelope
+// must carry valid signatures from at least threshold of the verifiers before
+// the layout is parsed.
+func LoadSignedLayout(path string, threshold int, verifiers ...dsse.Verifier) (*Layout, error) {
+	envelopeBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := &dsse.Envelope{}
+	if err := json.Unmarshal(envelopeBytes, envelope); err != nil {
+		return nil, err
+	}
+
+	if envelope.PayloadType != LayoutPayloadType {
+		return nil, fmt.Errorf("unexpected layout payload type %s", envelope.PayloadType)
+	}
+
+	envVerifier, err := dsse.NewMultiEnvelopeVerifier(threshold, verifiers...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := envVerifier.Verify(context.Background(), envelope); err != nil {
+		return nil, fmt.Errorf("unable to verify layout signatures: %w", err)
+	}
+
+	layoutBytes, err := envelope.DecodeB64Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLayout(layoutBytes, filepath.Dir(path), true)
+}
+
+// parseLayout parses the layout, reading functionary keys that are given as
+// files relative to dir. Those files aren't covered by a signed layout's
+// signature, so a signed layout must pin each of them with a declared keyID,
+// which is checked when functionaries are loaded.
+func parseLayout(layoutBytes []byte, dir string, signed bool) (*Layout, error) {
 	layout := &Layout{}
 	if err := yaml.Unmarshal(layoutBytes, layout); err != nil {
 		return nil, err
 	}
 
+	for name, functionary := range layout.Functionaries {
+		if len(functionary.KeyVal.PublicFile) == 0 {
+			continue
+		}
+
+		if len(functionary.KeyVal.Public) > 0 {
+			return nil, fmt.Errorf("functionary %s has both a public key and a public key file", name)
+		}
+
+		if signed && len(functionary.KeyID) == 0 {
+			return nil, fmt.Errorf("functionary %s of a signed layout must declare the keyID of its public key file", name)
+		}
+
+		keyPath := functionary.KeyVal.PublicFile
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(dir, keyPath)
+		}
+
+		keyBytes, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key for functionary %s: %w", name, err)
+		}
+
+		functionary.KeyVal.Public = string(keyBytes)
+		layout.Functionaries[name] = functionary
+	}
+
 	return layout, nil
 }
 
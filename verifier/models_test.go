@@ -0,0 +1,52 @@
+package verifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLayoutPublicFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "alice.pub"), []byte("alice's key"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		layout  string
+		signed  bool
+		wantErr string
+	}{
+		"unsigned without keyID": {
+			layout: "functionaries:\n  alice:\n    keyVal:\n      publicFile: alice.pub\n",
+		},
+		"signed without keyID": {
+			layout:  "functionaries:\n  alice:\n    keyVal:\n      publicFile: alice.pub\n",
+			signed:  true,
+			wantErr: "must declare the keyID",
+		},
+		"signed with keyID": {
+			layout: "functionaries:\n  alice:\n    keyID: abc\n    keyVal:\n      publicFile: alice.pub\n",
+			signed: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			layout, err := parseLayout([]byte(test.layout), dir, test.signed)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := layout.Functionaries["alice"].KeyVal.Public; got != "alice's key" {
+				t.Errorf("got public key %q, want the file's contents", got)
+			}
+		})
+	}
+}
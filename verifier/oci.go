@@ -0,0 +1,261 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ociDSSEMediaType            = "application/vnd.dsse.envelope.v1+json"
+	ociIndexMediaType           = "application/vnd.oci.image.index.v1+json"
+	dockerManifestListType      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociRefNameAnnotation        = "org.opencontainers.image.ref.name"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation       = "dev.sigstore.cosign/chain"
+)
+
+var ociDigest = regexp.MustCompile("^(sha256|sha512):([a-f0-9]+)$")
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+	Subject   *ociDescriptor  `json:"subject"`
+}
+
+// ociEntry is a manifest in an OCI image layout, along with the descriptor
+// it was found by.
+type ociEntry struct {
+	descriptor ociDescriptor
+	manifest   *ociManifest
+}
+
+// OCILayoutSource reads the attestations attached to images in an OCI image
+// layout directory. Attestations are attached either as referrers, manifests
+// whose subject is the image, or as cosign attestations, a manifest tagged
+// `sha256-<hex>.att`. Each DSSE envelope or Sigstore bundle layer of those
+// manifests is an attestation, named after the digest of its envelope.
+type OCILayoutSource struct {
+	dir string
+
+	index   []ociDescriptor
+	entries []ociEntry
+}
+
+func NewOCILayoutSource(dir string) *OCILayoutSource {
+	return &OCILayoutSource{dir: dir}
+}
+
+// Resolve returns the image with the reference, which is either its digest
+// or the name it's tagged with in the layout's index, as a subject.
+func (s *OCILayoutSource) Resolve(ref string) (*attestationv1.ResourceDescriptor, error) {
+	if err := s.load(log.NewEntry(log.StandardLogger())); err != nil {
+		return nil, err
+	}
+
+	digest := ""
+	if ociDigest.MatchString(ref) {
+		for _, entry := range s.entries {
+			if entry.descriptor.Digest == ref {
+				digest = ref
+				break
+			}
+		}
+	} else {
+		for _, descriptor := range s.index {
+			if refName := descriptor.Annotations[ociRefNameAnnotation]; refName == ref || strings.HasSuffix(refName, ":"+ref) {
+				digest = descriptor.Digest
+				break
+			}
+		}
+	}
+	if digest == "" {
+		return nil, fmt.Errorf("image %s not found in OCI layout %s", ref, s.dir)
+	}
+
+	algorithm, value, _ := strings.Cut(digest, ":")
+	return &attestationv1.ResourceDescriptor{Name: ref, Digest: map[string]string{algorithm: value}}, nil
+}
+
+// List returns the attestations attached to any image in the layout.
+func (s *OCILayoutSource) List(ctx context.Context) (map[string]*Attestation, error) {
+	logger := getLogger(ctx)
+	if err := s.load(logger); err != nil {
+		return nil, err
+	}
+
+	attestations := map[string]*Attestation{}
+	for _, entry := range s.entries {
+		if entry.manifest.Subject == nil && !strings.HasSuffix(entry.descriptor.Annotations[ociRefNameAnnotation], ".att") {
+			continue
+		}
+
+		if err := s.addAttestations(logger, attestations, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return attestations, nil
+}
+
+// Fetch returns the attestations attached to the image with the digest.
+func (s *OCILayoutSource) Fetch(ctx context.Context, digest string) (map[string]*Attestation, error) {
+	logger := getLogger(ctx)
+	if err := s.load(logger); err != nil {
+		return nil, err
+	}
+
+	cosignTag := strings.Replace(digest, ":", "-", 1) + ".att"
+	attestations := map[string]*Attestation{}
+	for _, entry := range s.entries {
+		refName := entry.descriptor.Annotations[ociRefNameAnnotation]
+		isReferrer := entry.manifest.Subject != nil && entry.manifest.Subject.Digest == digest
+		isCosignAttestation := refName == cosignTag || strings.HasSuffix(refName, ":"+cosignTag)
+		if !isReferrer && !isCosignAttestation {
+			continue
+		}
+
+		if err := s.addAttestations(logger, attestations, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return attestations, nil
+}
+
+// load reads the layout's index and every manifest it leads to.
+func (s *OCILayoutSource) load(logger *log.Entry) error {
+	if s.entries != nil {
+		return nil
+	}
+
+	indexBytes, err := os.ReadFile(filepath.Join(s.dir, "index.json"))
+	if err != nil {
+		return fmt.Errorf("unable to read OCI layout %s: %w", s.dir, err)
+	}
+
+	index := &ociManifest{}
+	if err := json.Unmarshal(indexBytes, index); err != nil {
+		return fmt.Errorf("unable to read OCI layout %s: %w", s.dir, err)
+	}
+	s.index = index.Manifests
+
+	entries := []ociEntry{}
+	seen := map[string]bool{}
+	queue := append([]ociDescriptor{}, index.Manifests...)
+	for len(queue) > 0 {
+		descriptor := queue[0]
+		queue = queue[1:]
+		if seen[descriptor.Digest] {
+			continue
+		}
+		seen[descriptor.Digest] = true
+
+		manifestBytes, err := s.readBlob(descriptor.Digest)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Layouts often only hold some of the platforms of an index.
+			logger.Infof("Manifest %s is not in OCI layout %s", descriptor.Digest, s.dir)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		manifest := &ociManifest{}
+		if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+			return fmt.Errorf("unable to read manifest %s: %w", descriptor.Digest, err)
+		}
+		entries = append(entries, ociEntry{descriptor: descriptor, manifest: manifest})
+
+		if manifest.MediaType == ociIndexMediaType || manifest.MediaType == dockerManifestListType || descriptor.MediaType == ociIndexMediaType {
+			queue = append(queue, manifest.Manifests...)
+		}
+	}
+	s.entries = entries
+
+	return nil
+}
+
+// readBlob reads the blob with the digest, and checks that it has that
+// digest.
+func (s *OCILayoutSource) readBlob(digest string) ([]byte, error) {
+	matches := ociDigest.FindStringSubmatch(digest)
+	if matches == nil {
+		return nil, fmt.Errorf("unsupported digest %s", digest)
+	}
+
+	blob, err := os.ReadFile(filepath.Join(s.dir, "blobs", matches[1], matches[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	var h hash.Hash = sha256.New()
+	if matches[1] == "sha512" {
+		h = sha512.New()
+	}
+	h.Write(blob)
+	if hex.EncodeToString(h.Sum(nil)) != matches[2] {
+		return nil, fmt.Errorf("blob %s does not match its digest", digest)
+	}
+
+	return blob, nil
+}
+
+// addAttestations adds the DSSE envelope and Sigstore bundle layers of the
+// manifest. Cosign records the signing certificate of an envelope in its
+// layer's annotations.
+func (s *OCILayoutSource) addAttestations(logger *log.Entry, attestations map[string]*Attestation, entry ociEntry) error {
+	for _, layer := range entry.manifest.Layers {
+		if layer.MediaType != ociDSSEMediaType && !strings.HasPrefix(layer.MediaType, sigstoreBundleMediaTypePrefix) {
+			continue
+		}
+
+		contents, err := s.readBlob(layer.Digest)
+		if err != nil {
+			return err
+		}
+
+		attestation, err := ParseAttestation(contents)
+		if err != nil {
+			logger.Infof("Unable to parse attestation %s attached by %s: %s", layer.Digest, entry.descriptor.Digest, err)
+			continue
+		}
+
+		if certificate := layer.Annotations[cosignCertificateAnnotation]; len(certificate) > 0 && len(attestation.Certificates) == 0 {
+			certificates, err := cryptoutils.UnmarshalCertificatesFromPEM([]byte(certificate + "\n" + layer.Annotations[cosignChainAnnotation]))
+			if err != nil {
+				return fmt.Errorf("unable to parse certificate of attestation %s: %w", layer.Digest, err)
+			}
+			attestation.Certificates = certificates
+		}
+
+		name, err := getAttestationName(attestation)
+		if err != nil {
+			return err
+		}
+		attestations[name] = attestation
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package verifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+)
+
+// writeTestBlob adds the blob to the OCI layout and returns its digest.
+func writeTestBlob(t *testing.T, dir string, blob []byte) string {
+	t.Helper()
+
+	sum := sha256.Sum256(blob)
+	digest := hex.EncodeToString(sum[:])
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blobs", "sha256", digest), blob, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return "sha256:" + digest
+}
+
+func writeTestJSONBlob(t *testing.T, dir string, value any) string {
+	t.Helper()
+
+	blob, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writeTestBlob(t, dir, blob)
+}
+
+// The OCI layout source logs through the verification's logger, so what it
+// logs is part of the report.
+func TestOCILayoutSourceReportMessages(t *testing.T) {
+	dir := t.TempDir()
+
+	image := writeTestJSONBlob(t, dir, &ociManifest{MediaType: "application/vnd.oci.image.manifest.v1+json"})
+	malformed := writeTestBlob(t, dir, []byte("not an envelope"))
+	referrer := writeTestJSONBlob(t, dir, &ociManifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Subject:   &ociDescriptor{Digest: image},
+		Layers:    []ociDescriptor{{MediaType: ociDSSEMediaType, Digest: malformed}},
+	})
+	missing := "sha256:" + strings.Repeat("0", 64)
+
+	index, err := json.Marshal(&ociManifest{Manifests: []ociDescriptor{{Digest: image}, {Digest: referrer}, {Digest: missing}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), index, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	algorithm, value, _ := strings.Cut(image, ":")
+	subject := &attestationv1.ResourceDescriptor{Name: "image", Digest: map[string]string{algorithm: value}}
+	layout := &Layout{Expires: time.Now().Add(time.Hour).Format(time.RFC3339)}
+
+	report, err := Verify(layout, nil, nil, WithSubjects(subject), WithAttestationSource(NewOCILayoutSource(dir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Manifest " + missing + " is not in OCI layout", "Unable to parse attestation " + malformed} {
+		if !slices.ContainsFunc(report.Messages, func(message *Message) bool { return strings.HasPrefix(message.Message, want) }) {
+			t.Errorf("report has no message %q", want)
+		}
+	}
+}
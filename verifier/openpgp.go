@@ -0,0 +1,76 @@
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// openPGPKeyType identifies functionaries whose key is an armored OpenPGP
+// public key.
+const openPGPKeyType = "openpgp"
+
+// openPGPVerifier verifies detached OpenPGP signatures, either binary or
+// armored, made with the primary key or any signing subkey of an OpenPGP key.
+// Signatures from keys that are revoked or expired at the time of
+// verification are rejected.
+type openPGPVerifier struct {
+	keyID  string
+	entity *openpgp.Entity
+}
+
+func newOpenPGPVerifier(functionary Functionary) (*openPGPVerifier, error) {
+	entity, err := loadOpenPGPKey(functionary.KeyVal.Public)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load key %s: %w", functionary.KeyID, err)
+	}
+
+	return &openPGPVerifier{keyID: functionary.KeyID, entity: entity}, nil
+}
+
+// loadOpenPGPKey reads a single OpenPGP key from its armored serialization.
+func loadOpenPGPKey(public string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(public))
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("expected one OpenPGP key, found %d", len(entities))
+	}
+
+	return entities[0], nil
+}
+
+// openPGPKeyID returns the key's fingerprint, which in-toto uses as the key
+// ID of OpenPGP keys.
+func openPGPKeyID(entity *openpgp.Entity) string {
+	return hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+}
+
+func (v *openPGPVerifier) Verify(_ context.Context, data, sig []byte) error {
+	keyring := openpgp.EntityList{v.entity}
+	config := &packet.Config{}
+
+	var err error
+	if bytes.HasPrefix(sig, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(sig), config)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(sig), config)
+	}
+
+	return err
+}
+
+func (v *openPGPVerifier) KeyID() (string, error) {
+	return v.keyID, nil
+}
+
+func (v *openPGPVerifier) Public() crypto.PublicKey {
+	return v.entity.PrimaryKey.PublicKey
+}
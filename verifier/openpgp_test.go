@@ -0,0 +1,183 @@
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func newTestOpenPGPKey(t *testing.T, config *packet.Config) *openpgp.Entity {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("Alice", "", "alice@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entity
+}
+
+func armorTestOpenPGPKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	writer, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(writer); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func signTestOpenPGP(t *testing.T, entity *openpgp.Entity, data []byte, armored bool, config *packet.Config) []byte {
+	t.Helper()
+
+	sig := &bytes.Buffer{}
+	sign := openpgp.DetachSign
+	if armored {
+		sign = openpgp.ArmoredDetachSign
+	}
+	if err := sign(sig, entity, bytes.NewReader(data), config); err != nil {
+		t.Fatal(err)
+	}
+
+	return sig.Bytes()
+}
+
+func TestOpenPGPVerifier(t *testing.T) {
+	data := []byte("attestation-verifier known answer")
+
+	primary := newTestOpenPGPKey(t, nil)
+
+	withSubkey := newTestOpenPGPKey(t, nil)
+	if err := withSubkey.AddSigningSubkey(nil); err != nil {
+		t.Fatal(err)
+	}
+	subkeyID := withSubkey.Subkeys[len(withSubkey.Subkeys)-1].PublicKey.KeyId
+	subkeySig := signTestOpenPGP(t, withSubkey, data, false, &packet.Config{SigningKeyId: subkeyID})
+	if sig, err := packet.Read(bytes.NewReader(subkeySig)); err != nil || *sig.(*packet.Signature).IssuerKeyId != subkeyID {
+		t.Fatalf("signature isn't by the subkey: %v", err)
+	}
+
+	// The key was made two hours ago and expired after one.
+	past := &packet.Config{Time: func() time.Time { return time.Now().Add(-2 * time.Hour) }, KeyLifetimeSecs: 3600}
+	expired := newTestOpenPGPKey(t, past)
+	expiredSig := signTestOpenPGP(t, expired, data, false, past)
+
+	revoked := newTestOpenPGPKey(t, nil)
+	revokedSig := signTestOpenPGP(t, revoked, data, false, nil)
+	if err := revoked.RevokeKey(packet.KeyCompromised, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		key     *openpgp.Entity
+		data    []byte
+		sig     []byte
+		wantErr bool
+		errIs   error
+	}{
+		"binary signature": {
+			key: primary,
+			sig: signTestOpenPGP(t, primary, data, false, nil),
+		},
+		"armored signature": {
+			key: primary,
+			sig: signTestOpenPGP(t, primary, data, true, nil),
+		},
+		"signing subkey": {
+			key: withSubkey,
+			sig: subkeySig,
+		},
+		"other data": {
+			key:     primary,
+			data:    []byte("other data"),
+			sig:     signTestOpenPGP(t, primary, data, false, nil),
+			wantErr: true,
+		},
+		"other key": {
+			key:     primary,
+			sig:     signTestOpenPGP(t, withSubkey, data, false, nil),
+			wantErr: true,
+		},
+		"expired key": {
+			key:     expired,
+			sig:     expiredSig,
+			wantErr: true,
+			errIs:   pgperrors.ErrKeyExpired,
+		},
+		"revoked key": {
+			key:     revoked,
+			sig:     revokedSig,
+			wantErr: true,
+			errIs:   pgperrors.ErrKeyRevoked,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			verifier, err := newOpenPGPVerifier(Functionary{KeyType: openPGPKeyType, KeyID: "test", KeyVal: KeyVal{Public: armorTestOpenPGPKey(t, test.key)}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed := data
+			if test.data != nil {
+				signed = test.data
+			}
+
+			err = verifier.Verify(context.Background(), signed, test.sig)
+			if (err != nil) != test.wantErr || (test.errIs != nil && !errors.Is(err, test.errIs)) {
+				t.Errorf("got error %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFunctionariesOpenPGP(t *testing.T) {
+	entity := newTestOpenPGPKey(t, nil)
+	public := armorTestOpenPGPKey(t, entity)
+	fingerprint := openPGPKeyID(entity)
+
+	tests := map[string]struct {
+		keyID   string
+		wantErr string
+	}{
+		"fingerprint":          {},
+		"declared fingerprint": {keyID: strings.ToUpper(fingerprint)},
+		"other keyID":          {keyID: "abcd", wantErr: "does not match fingerprint " + fingerprint},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			functionaries, err := loadFunctionaries(map[string]Functionary{"alice": {KeyType: openPGPKeyType, KeyID: test.keyID, KeyVal: KeyVal{Public: public}}})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if keyID := functionaries["alice"].KeyID; !strings.EqualFold(keyID, fingerprint) {
+				t.Errorf("got keyID %s, want %s", keyID, fingerprint)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package verifier
+
+import (
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	"github.com/sigstore/sigstore-go/pkg/root"
+)
+
+type verifyOptions struct {
+	subjects    []*attestationv1.ResourceDescriptor
+	workspace   string
+	trustedRoot root.TrustedMaterial
+	source      AttestationSource
+}
+
+type VerifyOption func(*verifyOptions)
+
+// WithSubjects sets the final artifacts of the supply chain. They're checked
+// against the subjects section of the layout.
+func WithSubjects(subjects ...*attestationv1.ResourceDescriptor) VerifyOption {
+	return func(o *verifyOptions) {
+		o.subjects = append(o.subjects, subjects...)
+	}
+}
+
+// WithWorkspace sets the directory the layout's inspections are run in. It
+// defaults to the current working directory.
+func WithWorkspace(workspace string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.workspace = workspace
+	}
+}
+
+// WithTrustedRoot sets the Sigstore trusted root used to verify the
+// certificates and timestamps of attestations signed by Sigstore functionaries.
+func WithTrustedRoot(trustedRoot root.TrustedMaterial) VerifyOption {
+	return func(o *verifyOptions) {
+		o.trustedRoot = trustedRoot
+	}
+}
+
+// WithAttestationSource sets a source to fetch the attestations about the
+// subjects from, and transitively about their materials, in addition to the
+// attestations passed to Verify.
+func WithAttestationSource(source AttestationSource) VerifyOption {
+	return func(o *verifyOptions) {
+		o.source = source
+	}
+}
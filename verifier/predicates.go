@@ -0,0 +1,290 @@
+package verifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	linkPredicatev0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	provenancePredicatev1 "github.com/in-toto/attestation/go/predicates/provenance/v1"
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	provenancePredicatev02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// PredicateAdapter extracts what the verifier checks from statements with
+// one predicate type. Artifacts is required, while Command, Byproducts, and
+// Components may be left nil if the predicate type doesn't record them.
+type PredicateAdapter struct {
+	// Artifacts returns the statement's materials, which artifact rules
+	// check as materials, and products, which they check as products.
+	Artifacts func(statement *attestationv1.Statement) (materials, products []*attestationv1.ResourceDescriptor, err error)
+	// Command returns the command the statement records, which is checked
+	// against the step's command, and false if it doesn't record one.
+	Command func(statement *attestationv1.Statement) (command []string, ok bool, err error)
+	// Byproducts returns the statement's byproducts, which attribute rules
+	// can check as `byproducts`.
+	Byproducts func(statement *attestationv1.Statement) (map[string]any, error)
+	// Components returns the packages and files listed by the statement,
+	// which attribute rules can check as `components`, `licenses`, and
+	// `purls`.
+	Components func(statement *attestationv1.Statement) ([]*Component, error)
+}
+
+// Default artifacts decide how the artifacts of statements whose predicate
+// type has no adapter are checked.
+const (
+	// defaultArtifactsMaterials treats subjects as materials. This is the
+	// default.
+	defaultArtifactsMaterials = "materials"
+	// defaultArtifactsProducts treats subjects as products.
+	defaultArtifactsProducts = "products"
+	// defaultArtifactsNone leaves those statements without artifacts.
+	defaultArtifactsNone = "none"
+)
+
+var (
+	predicateAdaptersMu sync.RWMutex
+	predicateAdapters   = map[string]PredicateAdapter{}
+)
+
+// RegisterPredicateAdapter registers the adapter for statements with the
+// predicate type, replacing any adapter registered for it before. Packages
+// that define predicate types usually call it from an init function.
+func RegisterPredicateAdapter(predicateType string, adapter PredicateAdapter) {
+	if adapter.Artifacts == nil {
+		panic(fmt.Sprintf("predicate adapter for %s has no Artifacts function", predicateType))
+	}
+
+	predicateAdaptersMu.Lock()
+	defer predicateAdaptersMu.Unlock()
+	predicateAdapters[predicateType] = adapter
+}
+
+func getPredicateAdapter(predicateType string) (PredicateAdapter, bool) {
+	predicateAdaptersMu.RLock()
+	defer predicateAdaptersMu.RUnlock()
+	adapter, ok := predicateAdapters[predicateType]
+	return adapter, ok
+}
+
+func init() {
+	RegisterPredicateAdapter(linkPredicateType, PredicateAdapter{
+		Artifacts: func(statement *attestationv1.Statement) ([]*attestationv1.ResourceDescriptor, []*attestationv1.ResourceDescriptor, error) {
+			link, err := getLink(statement)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return link.Materials, statement.Subject, nil
+		},
+		Command: func(statement *attestationv1.Statement) ([]string, bool, error) {
+			link, err := getLink(statement)
+			if err != nil {
+				return nil, false, err
+			}
+
+			// Links always record a command, so an empty one is compared
+			// like any other rather than skipped.
+			return link.Command, true, nil
+		},
+		Byproducts: func(statement *attestationv1.Statement) (map[string]any, error) {
+			link, err := getLink(statement)
+			if err != nil {
+				return nil, err
+			}
+
+			return link.GetByproducts().AsMap(), nil
+		},
+	})
+
+	RegisterPredicateAdapter("https://slsa.dev/provenance/v1", PredicateAdapter{
+		Artifacts: func(statement *attestationv1.Statement) ([]*attestationv1.ResourceDescriptor, []*attestationv1.ResourceDescriptor, error) {
+			provenance, err := getProvenancev1(statement)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return provenance.GetBuildDefinition().GetResolvedDependencies(), statement.Subject, nil
+		},
+		Command: func(statement *attestationv1.Statement) ([]string, bool, error) {
+			provenance, err := getProvenancev1(statement)
+			if err != nil {
+				return nil, false, err
+			}
+
+			command, ok := provenance.GetBuildDefinition().GetExternalParameters().AsMap()["command"]
+			if !ok {
+				return nil, false, nil
+			}
+
+			return getCommandFromParameter(command)
+		},
+	})
+
+	// TODO: assumes provenance v0.2 is in statement v1
+	RegisterPredicateAdapter("https://slsa.dev/provenance/v0.2", PredicateAdapter{
+		Artifacts: func(statement *attestationv1.Statement) ([]*attestationv1.ResourceDescriptor, []*attestationv1.ResourceDescriptor, error) {
+			provenance, err := getProvenancev02(statement)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			materials := []*attestationv1.ResourceDescriptor{}
+			for _, material := range provenance.Materials {
+				materials = append(materials, &attestationv1.ResourceDescriptor{
+					Name:   material.URI, // TODO: figure this out
+					Uri:    material.URI,
+					Digest: material.Digest,
+				})
+			}
+
+			return materials, statement.Subject, nil
+		},
+		Command: func(statement *attestationv1.Statement) ([]string, bool, error) {
+			provenance, err := getProvenancev02(statement)
+			if err != nil {
+				return nil, false, err
+			}
+
+			parameters, ok := provenance.Invocation.Parameters.(map[string]any)
+			if !ok {
+				return nil, false, nil
+			}
+
+			command, ok := parameters["command"]
+			if !ok {
+				return nil, false, nil
+			}
+
+			return getCommandFromParameter(command)
+		},
+	})
+}
+
+// getMaterialsAndProducts returns the statement's materials and products
+// according to the adapter for its predicate type. Statements without an
+// adapter are handled as the layout's default artifacts say.
+func getMaterialsAndProducts(statement *attestationv1.Statement, defaultArtifacts string) (materials, products []*attestationv1.ResourceDescriptor, err error) {
+	defer recoverAdapter(statement.PredicateType, &err)
+
+	if adapter, ok := getPredicateAdapter(statement.PredicateType); ok {
+		return adapter.Artifacts(statement)
+	}
+
+	switch defaultArtifacts {
+	case defaultArtifactsProducts:
+		return nil, statement.Subject, nil
+	case defaultArtifactsNone:
+		return nil, nil, nil
+	default:
+		return statement.Subject, nil, nil
+	}
+}
+
+// getClaimedCommand returns the command recorded by the statement, according
+// to the adapter for its predicate type. Link predicates record it directly,
+// and SLSA provenance in its `command` external parameter. The second return
+// value is false if the claim doesn't record a command.
+func getClaimedCommand(statement *attestationv1.Statement) (command []string, ok bool, err error) {
+	defer recoverAdapter(statement.PredicateType, &err)
+
+	adapter, found := getPredicateAdapter(statement.PredicateType)
+	if !found || adapter.Command == nil {
+		return nil, false, nil
+	}
+
+	return adapter.Command(statement)
+}
+
+// getByproducts returns the byproducts recorded by the statement, or an empty
+// map if its predicate type doesn't record any.
+func getByproducts(statement *attestationv1.Statement) (byproducts map[string]any, err error) {
+	defer recoverAdapter(statement.PredicateType, &err)
+
+	adapter, ok := getPredicateAdapter(statement.PredicateType)
+	if !ok || adapter.Byproducts == nil {
+		return map[string]any{}, nil
+	}
+
+	byproducts, err = adapter.Byproducts(statement)
+	if err != nil {
+		return nil, err
+	}
+	if byproducts == nil {
+		byproducts = map[string]any{}
+	}
+
+	return byproducts, nil
+}
+
+// recoverAdapter turns a panic in a predicate adapter into an error. Adapters
+// also run on statements that haven't been verified yet, so a malformed
+// statement mustn't crash the verifier.
+func recoverAdapter(predicateType string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("predicate adapter for %s failed: %v", predicateType, r)
+	}
+}
+
+func getCommandFromParameter(parameter any) ([]string, bool, error) {
+	switch command := parameter.(type) {
+	case string:
+		return strings.Fields(command), true, nil
+	case []any:
+		args := make([]string, 0, len(command))
+		for _, arg := range command {
+			argS, ok := arg.(string)
+			if !ok {
+				return nil, false, fmt.Errorf("invalid command argument %v", arg)
+			}
+			args = append(args, argS)
+		}
+		return args, true, nil
+	default:
+		return nil, false, fmt.Errorf("invalid command %v", parameter)
+	}
+}
+
+func getLink(statement *attestationv1.Statement) (*linkPredicatev0.Link, error) {
+	linkBytes, err := json.Marshal(statement.Predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &linkPredicatev0.Link{}
+	if err := protojson.Unmarshal(linkBytes, link); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func getProvenancev1(statement *attestationv1.Statement) (*provenancePredicatev1.Provenance, error) {
+	provenanceBytes, err := json.Marshal(statement.Predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	provenance := &provenancePredicatev1.Provenance{}
+	if err := protojson.Unmarshal(provenanceBytes, provenance); err != nil {
+		return nil, err
+	}
+
+	return provenance, nil
+}
+
+func getProvenancev02(statement *attestationv1.Statement) (*provenancePredicatev02.ProvenancePredicate, error) {
+	provenanceBytes, err := json.Marshal(statement.Predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	provenance := &provenancePredicatev02.ProvenancePredicate{}
+	if err := json.Unmarshal(provenanceBytes, provenance); err != nil {
+		return nil, err
+	}
+
+	return provenance, nil
+}
@@ -0,0 +1,153 @@
+package verifier
+
+import (
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	RulePassed  = "passed"
+	RuleFailed  = "failed"
+	RuleWarned  = "warned"
+	RuleSkipped = "skipped"
+)
+
+// Report is the machine-readable record of a verification run. It's populated
+// as verification proceeds, so a report returned alongside an error shows
+// everything evaluated up to the failure.
+type Report struct {
+	Verified    bool                `json:"verified"`
+	Error       string              `json:"error,omitempty"`
+	Steps       []*StepReport       `json:"steps,omitempty"`
+	Subjects    []*SubjectReport    `json:"subjects,omitempty"`
+	Inspections []*InspectionReport `json:"inspections,omitempty"`
+	Messages    []*Message          `json:"messages,omitempty"`
+}
+
+type StepReport struct {
+	Name       string             `json:"name"`
+	Predicates []*PredicateReport `json:"predicates"`
+}
+
+type SubjectReport struct {
+	Name       string             `json:"name"`
+	Digest     map[string]string  `json:"digest"`
+	Predicates []*PredicateReport `json:"predicates"`
+}
+
+// PredicateReport records the claims considered for an expected predicate.
+// Accepted counts the distinct owners of accepted claims, as listed in Owners,
+// which is what's compared against the threshold.
+type PredicateReport struct {
+	PredicateType string         `json:"predicateType"`
+	Functionaries []string       `json:"functionaries"`
+	Roles         []string       `json:"roles,omitempty"`
+	Threshold     int            `json:"threshold"`
+	Accepted      int            `json:"accepted"`
+	Owners        []string       `json:"owners"`
+	Claims        []*ClaimReport `json:"claims"`
+}
+
+func (p *PredicateReport) accept(owner string) {
+	if !slices.Contains(p.Owners, owner) {
+		p.Owners = append(p.Owners, owner)
+		p.Accepted += 1
+	}
+}
+
+func (p *PredicateReport) acceptedFunctionaries() []string {
+	accepted := []string{}
+	for _, claim := range p.Claims {
+		if claim.Accepted {
+			accepted = append(accepted, claim.Functionary)
+		}
+	}
+
+	return accepted
+}
+
+// ClaimReport records the checks applied to one functionary's claim.
+type ClaimReport struct {
+	Functionary string      `json:"functionary"`
+	Owner       string      `json:"owner"`
+	Attestation string      `json:"attestation"`
+	Accepted    bool        `json:"accepted"`
+	Rules       RuleResults `json:"rules"`
+	Errors      []string    `json:"errors,omitempty"`
+}
+
+// acceptedAttestations returns the names of the attestations that accepted
+// claims for steps or subjects came from.
+func (r *Report) acceptedAttestations() []string {
+	predicateReports := []*PredicateReport{}
+	for _, step := range r.Steps {
+		predicateReports = append(predicateReports, step.Predicates...)
+	}
+	for _, subject := range r.Subjects {
+		predicateReports = append(predicateReports, subject.Predicates...)
+	}
+
+	names := []string{}
+	for _, predicateReport := range predicateReports {
+		for _, claim := range predicateReport.Claims {
+			if claim.Accepted && !slices.Contains(names, claim.Attestation) {
+				names = append(names, claim.Attestation)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+type InspectionReport struct {
+	Name     string      `json:"name"`
+	Accepted bool        `json:"accepted"`
+	Rules    RuleResults `json:"rules"`
+	Errors   []string    `json:"errors,omitempty"`
+}
+
+type RuleResult struct {
+	Type    string `json:"type"`
+	Rule    string `json:"rule"`
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+type RuleResults []*RuleResult
+
+func (r *RuleResults) add(ruleType, rule, result, message string) {
+	*r = append(*r, &RuleResult{Type: ruleType, Rule: rule, Result: result, Message: message})
+}
+
+// set updates the result of the most recently added rule.
+func (r *RuleResults) set(result, message string) {
+	if len(*r) == 0 {
+		return
+	}
+
+	last := (*r)[len(*r)-1]
+	last.Result = result
+	last.Message = message
+}
+
+func (r *RuleResults) fail(err error) {
+	r.set(RuleFailed, err.Error())
+}
+
+type Message struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// Levels implements logrus.Hook so that everything logged during
+// verification is also captured in the report.
+func (r *Report) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (r *Report) Fire(entry *log.Entry) error {
+	r.Messages = append(r.Messages, &Message{Level: entry.Level.String(), Message: entry.Message})
+	return nil
+}
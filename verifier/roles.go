@@ -0,0 +1,91 @@
+package verifier
+
+import (
+	"fmt"
+	"slices"
+)
+
+// resolveFunctionaries returns the key IDs of the functionaries listed
+// directly along with those of the members of each role. Either may name a
+// functionary in the layout or give its key ID.
+func resolveFunctionaries(layout *Layout, functionaries, roles []string) ([]string, error) {
+	resolved := []string{}
+	add := func(member string) {
+		keyID := member
+		if functionary, ok := layout.Functionaries[member]; ok {
+			keyID = functionary.KeyID
+		}
+
+		if !slices.Contains(resolved, keyID) {
+			resolved = append(resolved, keyID)
+		}
+	}
+
+	for _, functionary := range functionaries {
+		add(functionary)
+	}
+
+	for _, roleName := range roles {
+		role, ok := layout.Roles[roleName]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %s", roleName)
+		}
+
+		for _, member := range role.Functionaries {
+			add(member)
+		}
+	}
+
+	return resolved, nil
+}
+
+// getOwner returns the identity of the person or system that holds the
+// functionary's key, which defaults to the key itself.
+func getOwner(layout *Layout, keyID string) string {
+	if functionary, ok := getFunctionary(layout.Functionaries, keyID); ok && len(functionary.Owner) > 0 {
+		return functionary.Owner
+	}
+
+	return keyID
+}
+
+// getOwners returns the distinct owners of the functionaries.
+func getOwners(layout *Layout, keyIDs []string) []string {
+	owners := []string{}
+	for _, keyID := range keyIDs {
+		if owner := getOwner(layout, keyID); !slices.Contains(owners, owner) {
+			owners = append(owners, owner)
+		}
+	}
+
+	return owners
+}
+
+// checkRoleThresholds checks that enough distinct owners among the members of
+// each role made accepted claims.
+func checkRoleThresholds(layout *Layout, roles []string, accepted []string) error {
+	for _, roleName := range roles {
+		role := layout.Roles[roleName]
+		if role.Threshold == 0 {
+			continue
+		}
+
+		members, err := resolveFunctionaries(layout, nil, []string{roleName})
+		if err != nil {
+			return err
+		}
+
+		acceptedMembers := []string{}
+		for _, keyID := range accepted {
+			if slices.Contains(members, keyID) {
+				acceptedMembers = append(acceptedMembers, keyID)
+			}
+		}
+
+		if count := len(getOwners(layout, acceptedMembers)); count < role.Threshold {
+			return fmt.Errorf("threshold not met for role %s: %d of %d", roleName, count, role.Threshold)
+		}
+	}
+
+	return nil
+}
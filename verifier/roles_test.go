@@ -0,0 +1,30 @@
+package verifier
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveFunctionaries(t *testing.T) {
+	layout := &Layout{
+		Functionaries: map[string]Functionary{
+			"alice": {KeyID: "a1"},
+			"bob":   {KeyID: "b1"},
+		},
+		Roles: map[string]Role{
+			"builders": {Functionaries: []string{"bob", "c1"}},
+		},
+	}
+
+	resolved, err := resolveFunctionaries(layout, []string{"alice", "b1"}, []string{"builders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1", "b1", "c1"}; !slices.Equal(resolved, want) {
+		t.Errorf("got %v, want %v", resolved, want)
+	}
+
+	if _, err := resolveFunctionaries(layout, nil, []string{"testers"}); err == nil {
+		t.Error("unknown role: got no error")
+	}
+}
@@ -1,25 +1,41 @@
 package verifier
 
 import (
-	"encoding/json"
 	"fmt"
 	"path"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/interpreter"
-	linkPredicatev0 "github.com/in-toto/attestation/go/predicates/link/v0"
-	provenancePredicatev1 "github.com/in-toto/attestation/go/predicates/provenance/v1"
 	attestationv1 "github.com/in-toto/attestation/go/v1"
 	"github.com/in-toto/in-toto-golang/in_toto"
-	provenancePredicatev02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
 	log "github.com/sirupsen/logrus"
-	"google.golang.org/protobuf/encoding/protojson"
 )
 
-func applyArtifactRules(statement *attestationv1.Statement, materialRules []string, productRules []string, claims map[string]map[AttestationIdentifier]*attestationv1.Statement) error {
-	materialsList, productsList, err := getMaterialsAndProducts(statement)
+// Match policies decide whether the accepted claims for the destination step
+// of a MATCH rule have to agree on the artifacts they record.
+const (
+	// matchPolicyAny matches artifacts recorded by any accepted claim for
+	// the destination step. This is the default.
+	matchPolicyAny = "any"
+	// matchPolicyAll requires every accepted claim for the destination step
+	// that records an artifact to record it with the same digest.
+	matchPolicyAll = "all"
+	// matchPolicyVerified is an alias of matchPolicyAll.
+	matchPolicyVerified = "verified"
+)
+
+func applyArtifactRules(logger *log.Entry, statement *attestationv1.Statement, materialRules []string, productRules []string, claims map[string]map[AttestationIdentifier]*attestationv1.Statement, layout *Layout, results *RuleResults) (err error) {
+	evaluated := len(*results)
+	defer func() {
+		if err != nil && len(*results) > evaluated {
+			results.fail(err)
+		}
+	}()
+
+	materialsList, productsList, err := getMaterialsAndProducts(statement, layout.DefaultArtifacts)
 	if err != nil {
 		return err
 	}
@@ -50,9 +66,10 @@ func applyArtifactRules(statement *attestationv1.Statement, materialRules []stri
 		}
 	}
 
-	log.Infof("Applying material rules...")
+	logger.Infof("Applying material rules...")
 	for _, r := range materialRules {
-		log.Infof("Evaluating rule `%s`...", r)
+		logger.Infof("Evaluating rule `%s`...", r)
+		results.add("material", r, RulePassed, "")
 		rule, err := in_toto.UnpackRule(strings.Split(r, " "))
 		if err != nil {
 			return err
@@ -62,7 +79,10 @@ func applyArtifactRules(statement *attestationv1.Statement, materialRules []stri
 		var consumed in_toto.Set
 		switch rule["type"] {
 		case "match":
-			consumed = applyMatchRule(rule, materials, materialsPaths, claims)
+			consumed, err = applyMatchRule(rule, materials, materialsPaths, claims, layout)
+			if err != nil {
+				return fmt.Errorf("materials verification failed: %w", err)
+			}
 		case "allow":
 			consumed = filtered
 		case "delete":
@@ -82,9 +102,10 @@ func applyArtifactRules(statement *attestationv1.Statement, materialRules []stri
 	}
 
 	// I've separated these out on purpose right now
-	log.Infof("Applying product rules...")
+	logger.Infof("Applying product rules...")
 	for _, r := range productRules {
-		log.Infof("Evaluating rule `%s`...", r)
+		logger.Infof("Evaluating rule `%s`...", r)
+		results.add("product", r, RulePassed, "")
 		rule, err := in_toto.UnpackRule(strings.Split(r, " "))
 		if err != nil {
 			return err
@@ -94,7 +115,10 @@ func applyArtifactRules(statement *attestationv1.Statement, materialRules []stri
 		var consumed in_toto.Set
 		switch rule["type"] {
 		case "match":
-			consumed = applyMatchRule(rule, products, productsPaths, claims)
+			consumed, err = applyMatchRule(rule, products, productsPaths, claims, layout)
+			if err != nil {
+				return fmt.Errorf("products verification failed: %w", err)
+			}
 		case "allow":
 			consumed = filtered
 		case "create":
@@ -118,10 +142,18 @@ func applyArtifactRules(statement *attestationv1.Statement, materialRules []stri
 	return nil
 }
 
-func applyAttributeRules(env *cel.Env, input interpreter.Activation, rules []Constraint) error {
-	log.Infof("Applying attribute rules...")
+func applyAttributeRules(logger *log.Entry, env *cel.Env, input interpreter.Activation, rules []Constraint, results *RuleResults) (err error) {
+	evaluated := len(*results)
+	defer func() {
+		if err != nil && len(*results) > evaluated {
+			results.fail(err)
+		}
+	}()
+
+	logger.Infof("Applying attribute rules...")
 	for _, r := range rules {
-		log.Infof("Evaluating rule `%s`...", r.Rule)
+		logger.Infof("Evaluating rule `%s`...", r.Rule)
+		results.add("attribute", r.Rule, RulePassed, "")
 		ast, issues := env.Compile(r.Rule)
 		if issues != nil && issues.Err() != nil {
 			return issues.Err()
@@ -135,6 +167,7 @@ func applyAttributeRules(env *cel.Env, input interpreter.Activation, rules []Con
 		out, _, err := prog.Eval(input)
 		if err != nil {
 			if strings.Contains(err.Error(), "no such attribute") || strings.Contains(err.Error(), "no such key") && r.AllowIfNoClaim {
+				results.set(RuleSkipped, err.Error())
 				continue
 			}
 			return err
@@ -150,13 +183,14 @@ func applyAttributeRules(env *cel.Env, input interpreter.Activation, rules []Con
 				}
 
 				if !r.Warn {
-					return fmt.Errorf(message)
+					return fmt.Errorf("%s", message)
 				}
 
-				log.Warnf("%s", message)
+				logger.Warnf("%s", message)
+				results.set(RuleWarned, message)
 			}
 		case error:
-			log.Info(result)
+			logger.Info(result)
 			return fmt.Errorf("CEL error: %w", result)
 		}
 	}
@@ -164,82 +198,70 @@ func applyAttributeRules(env *cel.Env, input interpreter.Activation, rules []Con
 	return nil
 }
 
-func getMaterialsAndProducts(statement *attestationv1.Statement) ([]*attestationv1.ResourceDescriptor, []*attestationv1.ResourceDescriptor, error) {
-	switch statement.PredicateType {
-	case "https://in-toto.io/attestation/link/v0.3":
-		linkBytes, err := json.Marshal(statement.Predicate)
-		if err != nil {
-			return nil, nil, err
-		}
-
-		link := &linkPredicatev0.Link{}
-		if err := protojson.Unmarshal(linkBytes, link); err != nil {
-			return nil, nil, err
-		}
-
-		return link.Materials, statement.Subject, nil
-
-	case "https://slsa.dev/provenance/v1":
-		provenanceBytes, err := json.Marshal(statement.Predicate)
-		if err != nil {
-			return nil, nil, err
-		}
+func applyCommandRule(logger *log.Entry, statement *attestationv1.Statement, step *Step, results *RuleResults) (err error) {
+	if len(step.Command) == 0 {
+		return nil
+	}
 
-		provenance := &provenancePredicatev1.Provenance{}
-		if err := protojson.Unmarshal(provenanceBytes, provenance); err != nil {
-			return nil, nil, err
+	evaluated := len(*results)
+	defer func() {
+		if err != nil && len(*results) > evaluated {
+			results.fail(err)
 		}
+	}()
 
-		return provenance.BuildDefinition.ResolvedDependencies, statement.Subject, nil
-
-	case "https://slsa.dev/provenance/v0.2":
-		// TODO: assumes provenance v0.2 is in statement v1
+	logger.Infof("Checking command `%s`...", step.Command)
+	results.add("command", step.Command, RulePassed, "")
+	claimedCommand, ok, err := getClaimedCommand(statement)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		logger.Infof("Claim of type %s does not record a command, skipping...", statement.PredicateType)
+		results.set(RuleSkipped, "claim does not record a command")
+		return nil
+	}
 
-		provenanceBytes, err := json.Marshal(statement.Predicate)
+	expectedCommand := strings.Fields(step.Command)
+	var matched bool
+	switch step.CommandMatch {
+	case "", "exact":
+		matched = reflect.DeepEqual(expectedCommand, claimedCommand)
+	case "prefix":
+		matched = len(claimedCommand) >= len(expectedCommand) && reflect.DeepEqual(expectedCommand, claimedCommand[:len(expectedCommand)])
+	case "glob":
+		matched, err = match(strings.Join(expectedCommand, " "), strings.Join(claimedCommand, " "))
 		if err != nil {
-			return nil, nil, err
-		}
-
-		provenance := &provenancePredicatev02.ProvenancePredicate{}
-		if err := json.Unmarshal(provenanceBytes, provenance); err != nil {
-			return nil, nil, err
+			return err
 		}
+	default:
+		return fmt.Errorf("invalid command match mode %s", step.CommandMatch)
+	}
 
-		materials := []*attestationv1.ResourceDescriptor{}
-		for _, material := range provenance.Materials {
-			materials = append(materials, &attestationv1.ResourceDescriptor{
-				Name:   material.URI, // TODO: figure this out
-				Uri:    material.URI,
-				Digest: material.Digest,
-			})
+	if !matched {
+		message := fmt.Sprintf("expected command `%s`, claim has `%s`", step.Command, strings.Join(claimedCommand, " "))
+		if !step.WarnOnCommandMismatch {
+			return fmt.Errorf("%s", message)
 		}
 
-		return materials, statement.Subject, nil
-
-	default:
-		return statement.Subject, nil, nil
+		logger.Warnf("%s", message)
+		results.set(RuleWarned, message)
 	}
+
+	return nil
 }
 
-func applyMatchRule(rule map[string]string, srcArtifacts map[string]*attestationv1.ResourceDescriptor, queue in_toto.Set, claims map[string]map[AttestationIdentifier]*attestationv1.Statement) in_toto.Set {
+func applyMatchRule(rule map[string]string, srcArtifacts map[string]*attestationv1.ResourceDescriptor, queue in_toto.Set, claims map[string]map[AttestationIdentifier]*attestationv1.Statement, layout *Layout) (in_toto.Set, error) {
 	consumed := in_toto.NewSet()
 
 	dstClaims, ok := claims[rule["dstName"]]
 	if !ok {
-		return consumed
+		return consumed, nil
 	}
 
-	dstMaterials, dstProducts, err := getDestinationArtifacts(dstClaims)
+	dstArtifactsByClaim, err := getDestinationArtifacts(dstClaims, rule["dstType"], layout.DefaultArtifacts)
 	if err != nil {
-		// FIXME: what is the right behaviour here across claims?
-		return consumed
-	}
-
-	var dstArtifacts map[string]*attestationv1.ResourceDescriptor
-	if rule["dstType"] == "materials" {
-		dstArtifacts = dstMaterials
-	} else {
-		dstArtifacts = dstProducts
+		return nil, fmt.Errorf("unable to read artifacts of step %s: %w", rule["dstName"], err)
 	}
 
 	if rule["pattern"] != "" {
@@ -253,13 +275,6 @@ func applyMatchRule(rule map[string]string, srcArtifacts map[string]*attestation
 		}
 	}
 
-	for p := range dstArtifacts {
-		if path.Clean(p) != p {
-			dstArtifacts[path.Clean(p)] = dstArtifacts[p]
-			delete(dstArtifacts, p)
-		}
-	}
-
 	for _, prefix := range []string{"srcPrefix", "dstPrefix"} {
 		if rule[prefix] != "" {
 			rule[prefix] = path.Clean(rule[prefix])
@@ -282,15 +297,33 @@ func applyMatchRule(rule map[string]string, srcArtifacts map[string]*attestation
 		// an optional destination prefix plus the source base path
 		dstPath := path.Clean(path.Join(rule["dstPrefix"], srcBasePath))
 
-		// Try to find the corresponding destination artifact
-		dstArtifact, exists := dstArtifacts[dstPath]
+		// Try to find the corresponding destination artifact in each of
+		// the destination step's claims
+		dstArtifacts := []*attestationv1.ResourceDescriptor{}
+		for _, claimArtifacts := range dstArtifactsByClaim {
+			if dstArtifact, exists := claimArtifacts[dstPath]; exists {
+				dstArtifacts = append(dstArtifacts, dstArtifact)
+			}
+		}
 		// Ignore artifacts without corresponding destination artifact
-		if !exists {
+		if len(dstArtifacts) == 0 {
 			continue
 		}
 
-		// Ignore artifact pairs with no matching hashes
-		if !reflect.DeepEqual(srcArtifacts[srcPath].Digest, dstArtifact.Digest) {
+		// Unless any claim will do, every claim for the destination step
+		// that records the artifact must record it with the same digest
+		if layout.MatchPolicy != matchPolicyAny && slices.ContainsFunc(dstArtifacts[1:], func(dstArtifact *attestationv1.ResourceDescriptor) bool {
+			return !reflect.DeepEqual(dstArtifacts[0].Digest, dstArtifact.Digest)
+		}) {
+			return nil, fmt.Errorf("claims for step %s disagree on %s %s", rule["dstName"], rule["dstType"], dstPath)
+		}
+
+		// Ignore artifact pairs with no matching hashes, including source
+		// artifacts without any hashes, like SBOM components without
+		// checksums
+		if len(srcArtifacts[srcPath].Digest) == 0 || !slices.ContainsFunc(dstArtifacts, func(dstArtifact *attestationv1.ResourceDescriptor) bool {
+			return reflect.DeepEqual(srcArtifacts[srcPath].Digest, dstArtifact.Digest)
+		}) {
 			continue
 		}
 
@@ -300,31 +333,31 @@ func applyMatchRule(rule map[string]string, srcArtifacts map[string]*attestation
 		consumed.Add(srcPath)
 	}
 
-	return consumed
+	return consumed, nil
 }
 
-func getDestinationArtifacts(dstClaims map[AttestationIdentifier]*attestationv1.Statement) (map[string]*attestationv1.ResourceDescriptor, map[string]*attestationv1.ResourceDescriptor, error) {
-	materials := map[string]*attestationv1.ResourceDescriptor{}
-	products := map[string]*attestationv1.ResourceDescriptor{}
+// getDestinationArtifacts returns the materials or products, depending on
+// dstType, of each of the destination step's claims, keyed by clean path.
+func getDestinationArtifacts(dstClaims map[AttestationIdentifier]*attestationv1.Statement, dstType string, defaultArtifacts string) ([]map[string]*attestationv1.ResourceDescriptor, error) {
+	artifactsByClaim := []map[string]*attestationv1.ResourceDescriptor{}
 
 	for _, claim := range dstClaims {
-		materialsList, productsList, err := getMaterialsAndProducts(claim)
+		materialsList, productsList, err := getMaterialsAndProducts(claim, defaultArtifacts)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
-		// FIXME: we're overwriting artifact info without checking if claims agree
-
-		for _, artifact := range materialsList {
-			artifact := artifact
-			materials[artifact.Name] = artifact
+		artifactsList := productsList
+		if dstType == "materials" {
+			artifactsList = materialsList
 		}
 
-		for _, artifact := range productsList {
-			artifact := artifact
-			products[artifact.Name] = artifact
+		artifacts := map[string]*attestationv1.ResourceDescriptor{}
+		for _, artifact := range artifactsList {
+			artifacts[path.Clean(artifact.Name)] = artifact
 		}
+		artifactsByClaim = append(artifactsByClaim, artifacts)
 	}
 
-	return materials, products, nil
+	return artifactsByClaim, nil
 }
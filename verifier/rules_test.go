@@ -0,0 +1,156 @@
+package verifier
+
+import (
+	"strings"
+	"testing"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestApplyMatchRulePolicies(t *testing.T) {
+	link := func(digest string) *attestationv1.Statement {
+		return &attestationv1.Statement{
+			PredicateType: linkPredicateType,
+			Predicate:     &structpb.Struct{Fields: map[string]*structpb.Value{"name": structpb.NewStringValue("clone")}},
+			Subject:       []*attestationv1.ResourceDescriptor{{Name: "foo", Digest: map[string]string{"sha256": digest}}},
+		}
+	}
+	// Test results record no products, so they don't take part in MATCH
+	// rules against products.
+	testResult := &attestationv1.Statement{
+		PredicateType: "https://in-toto.io/attestation/test-result/v0.1",
+		Subject:       []*attestationv1.ResourceDescriptor{{Name: "foo", Digest: map[string]string{"sha256": "aa"}}},
+	}
+
+	tests := map[string]struct {
+		policy  string
+		claims  []*attestationv1.Statement
+		matched bool
+		wantErr string
+	}{
+		"any with disagreeing claims": {
+			policy:  matchPolicyAny,
+			claims:  []*attestationv1.Statement{link("aa"), link("bb")},
+			matched: true,
+		},
+		"all with agreeing claims": {
+			policy:  matchPolicyAll,
+			claims:  []*attestationv1.Statement{link("aa"), link("aa")},
+			matched: true,
+		},
+		"all with disagreeing claims": {
+			policy:  matchPolicyAll,
+			claims:  []*attestationv1.Statement{link("aa"), link("bb")},
+			wantErr: "claims for step clone disagree on products foo",
+		},
+		"all with a claim that doesn't record the artifact": {
+			policy:  matchPolicyAll,
+			claims:  []*attestationv1.Statement{link("aa"), testResult},
+			matched: true,
+		},
+		"all with a mismatching artifact": {
+			policy: matchPolicyAll,
+			claims: []*attestationv1.Statement{link("bb")},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dstClaims := map[AttestationIdentifier]*attestationv1.Statement{}
+			for i, claim := range test.claims {
+				dstClaims[AttestationIdentifier{PredicateType: claim.PredicateType, Functionary: string(rune('a' + i))}] = claim
+			}
+			claims := map[string]map[AttestationIdentifier]*attestationv1.Statement{"clone": dstClaims}
+
+			rule, err := in_toto.UnpackRule(strings.Split("MATCH foo WITH products FROM clone", " "))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			srcArtifacts := map[string]*attestationv1.ResourceDescriptor{"foo": {Name: "foo", Digest: map[string]string{"sha256": "aa"}}}
+			consumed, err := applyMatchRule(rule, srcArtifacts, in_toto.NewSet("foo"), claims, &Layout{MatchPolicy: test.policy, DefaultArtifacts: defaultArtifactsNone})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if consumed.Has("foo") != test.matched {
+				t.Errorf("got matched %t, want %t", consumed.Has("foo"), test.matched)
+			}
+		})
+	}
+}
+
+func TestApplyCommandRule(t *testing.T) {
+	link := func(command ...any) *attestationv1.Statement {
+		predicate, err := structpb.NewStruct(map[string]any{"name": "clone", "command": command})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &attestationv1.Statement{PredicateType: linkPredicateType, Predicate: predicate}
+	}
+	provenance := &attestationv1.Statement{
+		PredicateType: "https://slsa.dev/provenance/v1",
+		Predicate:     &structpb.Struct{Fields: map[string]*structpb.Value{"buildDefinition": structpb.NewStructValue(&structpb.Struct{})}},
+	}
+
+	tests := map[string]struct {
+		statement  *attestationv1.Statement
+		step       *Step
+		wantResult string
+		wantErr    bool
+	}{
+		"exact": {
+			statement:  link("git", "clone", "https://example.com/foo.git"),
+			step:       &Step{Command: "git clone https://example.com/foo.git"},
+			wantResult: RulePassed,
+		},
+		"prefix": {
+			statement:  link("git", "clone", "https://example.com/foo.git"),
+			step:       &Step{Command: "git clone", CommandMatch: "prefix"},
+			wantResult: RulePassed,
+		},
+		"mismatch": {
+			statement:  link("git", "fetch"),
+			step:       &Step{Command: "git clone"},
+			wantResult: RuleFailed,
+			wantErr:    true,
+		},
+		"empty link command": {
+			statement:  link(),
+			step:       &Step{Command: "git clone"},
+			wantResult: RuleFailed,
+			wantErr:    true,
+		},
+		"empty link command with warning": {
+			statement:  link(),
+			step:       &Step{Command: "git clone", WarnOnCommandMismatch: true},
+			wantResult: RuleWarned,
+		},
+		"no command recorded": {
+			statement:  provenance,
+			step:       &Step{Command: "git clone"},
+			wantResult: RuleSkipped,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			results := RuleResults{}
+			err := applyCommandRule(log.NewEntry(log.New()), test.statement, test.step, &results)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %t", err, test.wantErr)
+			}
+			if len(results) != 1 || results[0].Result != test.wantResult {
+				t.Errorf("got results %v, want %s", results, test.wantResult)
+			}
+		})
+	}
+}
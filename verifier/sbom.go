@@ -0,0 +1,343 @@
+package verifier
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+)
+
+// Component is a package or file listed in an SBOM.
+type Component struct {
+	Name     string
+	Version  string
+	Purl     string
+	Digest   map[string]string
+	Licenses []string
+}
+
+func init() {
+	spdx := PredicateAdapter{Artifacts: getSBOMArtifacts, Components: getSPDXComponents}
+	for _, predicateType := range []string{"https://spdx.dev/Document", "https://spdx.dev/Document/v2.3", "https://spdx.dev/Document/v3.0"} {
+		RegisterPredicateAdapter(predicateType, spdx)
+	}
+
+	cycloneDX := PredicateAdapter{Artifacts: getSBOMArtifacts, Components: getCycloneDXComponents}
+	for _, predicateType := range []string{"https://cyclonedx.org/bom", "https://cyclonedx.org/bom/v1.5", "https://cyclonedx.org/bom/v1.6"} {
+		RegisterPredicateAdapter(predicateType, cycloneDX)
+	}
+}
+
+// getSBOMArtifacts returns the SBOM's components as materials, named by their
+// purl if they have one, and its subjects, which it describes, as products.
+func getSBOMArtifacts(statement *attestationv1.Statement) ([]*attestationv1.ResourceDescriptor, []*attestationv1.ResourceDescriptor, error) {
+	components, err := getComponents(statement)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	materials := make([]*attestationv1.ResourceDescriptor, 0, len(components))
+	for _, component := range components {
+		name := component.Purl
+		if name == "" {
+			name = component.Name
+		}
+		materials = append(materials, &attestationv1.ResourceDescriptor{
+			Name:   name,
+			Uri:    component.Purl,
+			Digest: component.Digest,
+		})
+	}
+
+	return materials, statement.Subject, nil
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdx2Document struct {
+	Packages []struct {
+		Name         string         `json:"name"`
+		VersionInfo  string         `json:"versionInfo"`
+		Checksums    []spdxChecksum `json:"checksums"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+	} `json:"packages"`
+	Files []struct {
+		FileName         string         `json:"fileName"`
+		Checksums        []spdxChecksum `json:"checksums"`
+		LicenseConcluded string         `json:"licenseConcluded"`
+	} `json:"files"`
+}
+
+type spdx3Element struct {
+	Type          string `json:"type"`
+	SPDXID        string `json:"spdxId"`
+	Name          string `json:"name"`
+	Version       string `json:"software_packageVersion"`
+	PackageURL    string `json:"software_packageUrl"`
+	VerifiedUsing []struct {
+		Algorithm string `json:"algorithm"`
+		HashValue string `json:"hashValue"`
+	} `json:"verifiedUsing"`
+	LicenseExpression string   `json:"simplelicensing_licenseExpression"`
+	RelationshipType  string   `json:"relationshipType"`
+	From              string   `json:"from"`
+	To                []string `json:"to"`
+}
+
+// getSPDXComponents returns the packages and files of an SPDX 2.x document,
+// or the packages and files in the `@graph` of an SPDX 3.0 document.
+func getSPDXComponents(statement *attestationv1.Statement) ([]*Component, error) {
+	predicateBytes, err := json.Marshal(statement.Predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := struct {
+		Graph []spdx3Element `json:"@graph"`
+	}{}
+	if err := json.Unmarshal(predicateBytes, &graph); err != nil {
+		return nil, err
+	}
+	if len(graph.Graph) > 0 {
+		return getSPDX3Components(graph.Graph), nil
+	}
+
+	document := &spdx2Document{}
+	if err := json.Unmarshal(predicateBytes, document); err != nil {
+		return nil, err
+	}
+
+	components := []*Component{}
+	for _, pkg := range document.Packages {
+		component := &Component{
+			Name:     pkg.Name,
+			Version:  pkg.VersionInfo,
+			Digest:   getSPDXDigest(pkg.Checksums),
+			Licenses: getSPDXLicenses(pkg.LicenseConcluded, pkg.LicenseDeclared),
+		}
+		for _, ref := range pkg.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				component.Purl = ref.ReferenceLocator
+				break
+			}
+		}
+		components = append(components, component)
+	}
+
+	for _, file := range document.Files {
+		components = append(components, &Component{
+			Name:     file.FileName,
+			Digest:   getSPDXDigest(file.Checksums),
+			Licenses: getSPDXLicenses(file.LicenseConcluded),
+		})
+	}
+
+	return components, nil
+}
+
+func getSPDX3Components(elements []spdx3Element) []*Component {
+	licenseExpressions := map[string]string{}
+	for _, element := range elements {
+		if element.LicenseExpression != "" {
+			licenseExpressions[element.SPDXID] = element.LicenseExpression
+		}
+	}
+
+	licenses := map[string][]string{}
+	for _, element := range elements {
+		if element.RelationshipType != "hasConcludedLicense" && element.RelationshipType != "hasDeclaredLicense" {
+			continue
+		}
+		for _, to := range element.To {
+			if expression, ok := licenseExpressions[to]; ok {
+				licenses[element.From] = append(licenses[element.From], getSPDXLicenses(expression)...)
+			}
+		}
+	}
+
+	components := []*Component{}
+	for _, element := range elements {
+		if element.Type != "software_Package" && element.Type != "software_File" {
+			continue
+		}
+
+		digest := map[string]string{}
+		for _, hash := range element.VerifiedUsing {
+			digest[normalizeDigestAlgorithm(hash.Algorithm)] = strings.ToLower(hash.HashValue)
+		}
+
+		components = append(components, &Component{
+			Name:     element.Name,
+			Version:  element.Version,
+			Purl:     element.PackageURL,
+			Digest:   digest,
+			Licenses: compactLicenses(licenses[element.SPDXID]),
+		})
+	}
+
+	return components
+}
+
+func getSPDXDigest(checksums []spdxChecksum) map[string]string {
+	digest := map[string]string{}
+	for _, checksum := range checksums {
+		digest[normalizeDigestAlgorithm(checksum.Algorithm)] = strings.ToLower(checksum.ChecksumValue)
+	}
+
+	return digest
+}
+
+// getSPDXLicenses returns the licenses that are set, leaving out SPDX's
+// placeholders for unknown licenses.
+func getSPDXLicenses(licenses ...string) []string {
+	set := []string{}
+	for _, license := range licenses {
+		if license != "" && license != "NOASSERTION" && license != "NONE" {
+			set = append(set, license)
+		}
+	}
+
+	return compactLicenses(set)
+}
+
+type cycloneDXComponent struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Purl    string `json:"purl"`
+	Hashes  []struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	} `json:"hashes"`
+	Licenses []struct {
+		License struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"license"`
+		Expression string `json:"expression"`
+	} `json:"licenses"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+// getCycloneDXComponents returns the components of a CycloneDX BOM, including
+// the components nested in them.
+func getCycloneDXComponents(statement *attestationv1.Statement) ([]*Component, error) {
+	predicateBytes, err := json.Marshal(statement.Predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	bom := struct {
+		Components []cycloneDXComponent `json:"components"`
+	}{}
+	if err := json.Unmarshal(predicateBytes, &bom); err != nil {
+		return nil, err
+	}
+
+	components := []*Component{}
+	queue := bom.Components
+	for len(queue) > 0 {
+		cdxComponent := queue[0]
+		queue = append(queue[1:], cdxComponent.Components...)
+
+		component := &Component{
+			Name:    cdxComponent.Name,
+			Version: cdxComponent.Version,
+			Purl:    cdxComponent.Purl,
+			Digest:  map[string]string{},
+		}
+		for _, hash := range cdxComponent.Hashes {
+			component.Digest[normalizeDigestAlgorithm(hash.Alg)] = strings.ToLower(hash.Content)
+		}
+		for _, license := range cdxComponent.Licenses {
+			switch {
+			case license.Expression != "":
+				component.Licenses = append(component.Licenses, license.Expression)
+			case license.License.ID != "":
+				component.Licenses = append(component.Licenses, license.License.ID)
+			case license.License.Name != "":
+				component.Licenses = append(component.Licenses, license.License.Name)
+			}
+		}
+		component.Licenses = compactLicenses(component.Licenses)
+
+		components = append(components, component)
+	}
+
+	return components, nil
+}
+
+// normalizeDigestAlgorithm turns the hash algorithm names used by SBOMs, like
+// SHA256, SHA-256, or SHA3-256, into in-toto's, like sha256 or sha3_256.
+func normalizeDigestAlgorithm(algorithm string) string {
+	algorithm = strings.ToLower(algorithm)
+	algorithm = strings.Replace(algorithm, "sha-", "sha", 1)
+	return strings.ReplaceAll(algorithm, "-", "_")
+}
+
+func compactLicenses(licenses []string) []string {
+	if licenses == nil {
+		return []string{}
+	}
+
+	return slices.Compact(slices.Sorted(slices.Values(licenses)))
+}
+
+// getComponents returns the components listed by the statement, or none if
+// its predicate type doesn't list any.
+func getComponents(statement *attestationv1.Statement) (components []*Component, err error) {
+	defer recoverAdapter(statement.PredicateType, &err)
+
+	adapter, ok := getPredicateAdapter(statement.PredicateType)
+	if !ok || adapter.Components == nil {
+		return []*Component{}, nil
+	}
+
+	return adapter.Components(statement)
+}
+
+// getComponentVariables returns the statement's components, along with their
+// distinct licenses and purls, for the CEL variables `components`, `licenses`,
+// and `purls`.
+func getComponentVariables(statement *attestationv1.Statement) ([]map[string]any, []string, []string, error) {
+	components, err := getComponents(statement)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	componentValues := make([]map[string]any, 0, len(components))
+	licenses := []string{}
+	purls := []string{}
+	for _, component := range components {
+		digest := component.Digest
+		if digest == nil {
+			digest = map[string]string{}
+		}
+		componentLicenses := component.Licenses
+		if componentLicenses == nil {
+			componentLicenses = []string{}
+		}
+
+		componentValues = append(componentValues, map[string]any{
+			"name":     component.Name,
+			"version":  component.Version,
+			"purl":     component.Purl,
+			"digest":   digest,
+			"licenses": componentLicenses,
+		})
+		licenses = append(licenses, componentLicenses...)
+		if component.Purl != "" {
+			purls = append(purls, component.Purl)
+		}
+	}
+
+	return componentValues, compactLicenses(licenses), slices.Compact(slices.Sorted(slices.Values(purls))), nil
+}
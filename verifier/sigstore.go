@@ -0,0 +1,83 @@
+package verifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	sigstoreverify "github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// sigstoreKeyType identifies functionaries that are Sigstore certificate
+// identities rather than keys, following securesystemslib's serialization.
+const sigstoreKeyType = "sigstore-oidc"
+
+// verifySigstoreIdentity checks that the attestation's leaf certificate was
+// issued by a Fulcio CA in the trusted root to the functionary's identity, and
+// that it was valid when the envelope was signed. The signing time must come
+// from a timestamp or transparency log entry verified against the trusted
+// root, as Fulcio certificates expire minutes after they're issued.
+func verifySigstoreIdentity(functionary Functionary, attestation *Attestation, logEntries []*tlog.Entry, trustedRoot root.TrustedMaterial) error {
+	leaf := attestation.Certificates[0]
+
+	signingTimes, err := getSigningTimes(attestation, logEntries, trustedRoot)
+	if err != nil {
+		return err
+	}
+	if len(signingTimes) == 0 {
+		return fmt.Errorf("no verified signing time")
+	}
+
+	for _, signingTime := range signingTimes {
+		if _, err := sigstoreverify.VerifyLeafCertificate(signingTime, leaf, trustedRoot); err != nil {
+			return fmt.Errorf("certificate not valid at %s: %w", signingTime, err)
+		}
+	}
+
+	identity, err := sigstoreverify.NewShortCertificateIdentity(functionary.KeyVal.Issuer, "", functionary.KeyVal.Identity, "")
+	if err != nil {
+		return err
+	}
+
+	summary, err := certificate.SummarizeCertificate(leaf)
+	if err != nil {
+		return err
+	}
+
+	return identity.Verify(summary)
+}
+
+// getSigningTimes returns the times at which the envelope's signature was
+// observed, according to the timestamps that verify against the trusted root
+// and the verified transparency log entries.
+func getSigningTimes(attestation *Attestation, logEntries []*tlog.Entry, trustedRoot root.TrustedMaterial) ([]time.Time, error) {
+	if len(attestation.Envelope.Signatures) != 1 {
+		return nil, fmt.Errorf("expected one signature for certificate, found %d", len(attestation.Envelope.Signatures))
+	}
+
+	sig, err := decodeSignature(attestation.Envelope.Signatures[0])
+	if err != nil {
+		return nil, err
+	}
+
+	signingTimes := getIntegratedTimes(logEntries)
+	if trustedRoot == nil {
+		return signingTimes, nil
+	}
+
+	for _, timestamp := range attestation.Timestamps {
+		for _, authority := range trustedRoot.TimestampingAuthorities() {
+			verifiedTimestamp, err := authority.Verify(timestamp, sig)
+			if err != nil {
+				continue
+			}
+
+			signingTimes = append(signingTimes, verifiedTimestamp.Time)
+			break
+		}
+	}
+
+	return signingTimes, nil
+}
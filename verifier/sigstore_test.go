@@ -0,0 +1,111 @@
+package verifier
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+)
+
+func TestVerifySigstoreIdentity(t *testing.T) {
+	fulcio := newTestCA(t, "fulcio")
+	otherCA := newTestCA(t, "other CA")
+	rekor := newTestLog(t)
+	key := newTestKey(t)
+
+	issuer, err := asn1.MarshalWithParams("https://token.actions.githubusercontent.com", "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := url.Parse("https://github.com/example/app/.github/workflows/release.yml@refs/heads/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fulcio certificates are only valid for a few minutes.
+	leaf := func(ca *testCA, notAfter time.Time) *x509.Certificate {
+		return ca.issue(t, &x509.Certificate{
+			URIs:            []*url.URL{identity},
+			NotBefore:       notAfter.Add(-10 * time.Minute),
+			NotAfter:        notAfter,
+			ExtraExtensions: []pkix.Extension{{Id: certificate.OIDIssuerV2, Value: issuer}},
+		}, key)
+	}
+
+	functionary := Functionary{
+		KeyType: sigstoreKeyType,
+		KeyID:   "release",
+		KeyVal:  KeyVal{Issuer: "https://token.actions.githubusercontent.com", Identity: identity.String()},
+	}
+	trustedRoot := rekor.trustedRoot()
+	trustedRoot.fulcioCAs = []root.CertificateAuthority{&root.FulcioCertificateAuthority{Root: fulcio.certificate}}
+
+	tests := map[string]struct {
+		certificate *x509.Certificate
+		functionary Functionary
+		logged      bool
+		wantErr     string
+	}{
+		"logged while valid": {
+			certificate: leaf(fulcio, time.Now().Add(5*time.Minute)),
+			functionary: functionary,
+			logged:      true,
+		},
+		"not logged": {
+			certificate: leaf(fulcio, time.Now().Add(5*time.Minute)),
+			functionary: functionary,
+			wantErr:     "no verified signing time",
+		},
+		"logged after expiry": {
+			certificate: leaf(fulcio, time.Now().Add(-5*time.Minute)),
+			functionary: functionary,
+			logged:      true,
+			wantErr:     "certificate not valid",
+		},
+		"other CA": {
+			certificate: leaf(otherCA, time.Now().Add(5*time.Minute)),
+			functionary: functionary,
+			logged:      true,
+			wantErr:     "certificate not valid",
+		},
+		"other issuer": {
+			certificate: leaf(fulcio, time.Now().Add(5*time.Minute)),
+			functionary: Functionary{KeyType: sigstoreKeyType, KeyID: "release", KeyVal: KeyVal{Issuer: "https://accounts.google.com", Identity: identity.String()}},
+			logged:      true,
+			wantErr:     "issuer",
+		},
+		"other identity": {
+			certificate: leaf(fulcio, time.Now().Add(5*time.Minute)),
+			functionary: Functionary{KeyType: sigstoreKeyType, KeyID: "release", KeyVal: KeyVal{Issuer: functionary.KeyVal.Issuer, Identity: "release@example.com"}},
+			logged:      true,
+			wantErr:     "SAN",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attestation := &Attestation{Envelope: newSignedTestEnvelope(t, testStatement, key, ""), Certificates: []*x509.Certificate{test.certificate}}
+			logEntries := []*tlog.Entry{}
+			if test.logged {
+				logEntries = append(logEntries, rekor.record(t, attestation.Envelope, key, true, false))
+			}
+
+			err := verifySigstoreIdentity(test.functionary, attestation, logEntries, trustedRoot)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
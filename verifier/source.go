@@ -0,0 +1,160 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// AttestationSource provides the attestations to verify, either all of them
+// or those about a particular artifact. Attestations are keyed by name, which
+// the default step binding takes the step name from.
+type AttestationSource interface {
+	// List returns every attestation in the source.
+	List(ctx context.Context) (map[string]*Attestation, error)
+	// Fetch returns the attestations with a subject that has the digest,
+	// given as `<algorithm>:<hex>`.
+	Fetch(ctx context.Context, digest string) (map[string]*Attestation, error)
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context that carries the logger to attestation
+// sources, so that what they log during a verification ends up in its report.
+func withLogger(ctx context.Context, logger *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// getLogger returns the logger carried by the context, or else the standard
+// logger.
+func getLogger(ctx context.Context) *log.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return logger
+	}
+
+	return log.NewEntry(log.StandardLogger())
+}
+
+// FetchAttestations fetches the attestations about the subjects from the
+// source, and then, transitively, the attestations about their materials, so
+// that every step that led to the subjects is covered. Attestations aren't
+// verified yet, so this may fetch more than is needed.
+func FetchAttestations(ctx context.Context, source AttestationSource, subjects []*attestationv1.ResourceDescriptor) (map[string]*Attestation, error) {
+	return fetchAttestations(ctx, log.NewEntry(log.StandardLogger()), source, subjects)
+}
+
+func fetchAttestations(ctx context.Context, logger *log.Entry, source AttestationSource, subjects []*attestationv1.ResourceDescriptor) (map[string]*Attestation, error) {
+	ctx = withLogger(ctx, logger)
+	attestations := map[string]*Attestation{}
+
+	queue := []string{}
+	for _, subject := range subjects {
+		queue = append(queue, getDigests(subject)...)
+	}
+
+	fetched := map[string]bool{}
+	for len(queue) > 0 {
+		digest := queue[0]
+		queue = queue[1:]
+		if fetched[digest] {
+			continue
+		}
+		fetched[digest] = true
+
+		logger.Infof("Fetching attestations for %s...", digest)
+		digestAttestations, err := source.Fetch(ctx, digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch attestations for %s: %w", digest, err)
+		}
+
+		for name, attestation := range digestAttestations {
+			if _, ok := attestations[name]; ok {
+				continue
+			}
+			attestations[name] = attestation
+
+			statement, err := decodeStatement(attestation)
+			if err != nil {
+				logger.Infof("Unable to read statement of %s: %s", name, err)
+				continue
+			}
+
+			materials, _, err := getMaterialsAndProducts(statement, defaultArtifactsMaterials)
+			if err != nil {
+				logger.Infof("Unable to read materials of %s: %s", name, err)
+				continue
+			}
+			for _, material := range materials {
+				queue = append(queue, getDigests(material)...)
+			}
+		}
+	}
+
+	return attestations, nil
+}
+
+// getDigests returns the artifact's digests as `<algorithm>:<hex>`.
+func getDigests(artifact *attestationv1.ResourceDescriptor) []string {
+	digests := []string{}
+	for _, algorithm := range slices.Sorted(maps.Keys(artifact.Digest)) {
+		digests = append(digests, fmt.Sprintf("%s:%s", algorithm, artifact.Digest[algorithm]))
+	}
+
+	return digests
+}
+
+// hasSubject reports whether the attestation's statement has a subject with
+// the digest, given as `<algorithm>:<hex>`. The signature isn't verified.
+func hasSubject(attestation *Attestation, digest string) bool {
+	algorithm, value, ok := strings.Cut(digest, ":")
+	if !ok {
+		return false
+	}
+
+	statement, err := decodeStatement(attestation)
+	if err != nil {
+		return false
+	}
+
+	return slices.ContainsFunc(statement.Subject, func(subject *attestationv1.ResourceDescriptor) bool {
+		return strings.EqualFold(subject.Digest[algorithm], value)
+	})
+}
+
+// decodeStatement decodes the attestation's statement without verifying its
+// signature.
+func decodeStatement(attestation *Attestation) (*attestationv1.Statement, error) {
+	payload, err := attestation.Envelope.DecodeB64Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &attestationv1.Statement{}
+	if err := protojson.Unmarshal(payload, statement); err != nil {
+		return nil, err
+	}
+
+	return statement, nil
+}
+
+// getAttestationName names an attestation that doesn't come from a file after
+// the digest of its envelope, so the same attestation fetched twice has the
+// same name.
+func getAttestationName(attestation *Attestation) (string, error) {
+	envelopeBytes, err := json.Marshal(attestation.Envelope)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(envelopeBytes)
+	return hex.EncodeToString(digest[:]), nil
+}
@@ -0,0 +1,70 @@
+package verifier
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/in-toto/in-toto-golang/in_toto"
+)
+
+// sortSteps orders the steps so that every step comes after the steps its
+// MATCH rules point at, as those rules only match claims that have already
+// been accepted. Otherwise, steps keep the order they're listed in. Steps are
+// told apart by name, so each name may only be used once.
+func sortSteps(steps []*Step) ([]*Step, error) {
+	names := map[string]bool{}
+	for _, step := range steps {
+		if names[step.Name] {
+			return nil, fmt.Errorf("more than one step is named %s", step.Name)
+		}
+		names[step.Name] = true
+	}
+
+	dependencies := map[string][]string{}
+	for _, step := range steps {
+		for _, r := range slices.Concat(step.ExpectedMaterials, step.ExpectedProducts) {
+			rule, err := in_toto.UnpackRule(strings.Split(r, " "))
+			if err != nil || rule["type"] != "match" {
+				// Invalid rules are reported when the step is verified.
+				continue
+			}
+
+			dstName := rule["dstName"]
+			if dstName == step.Name || !names[dstName] || slices.Contains(dependencies[step.Name], dstName) {
+				continue
+			}
+			dependencies[step.Name] = append(dependencies[step.Name], dstName)
+		}
+	}
+
+	sorted := make([]*Step, 0, len(steps))
+	done := map[string]bool{}
+	for len(sorted) < len(steps) {
+		next := slices.IndexFunc(steps, func(step *Step) bool {
+			if done[step.Name] {
+				return false
+			}
+			for _, dependency := range dependencies[step.Name] {
+				if !done[dependency] {
+					return false
+				}
+			}
+			return true
+		})
+		if next == -1 {
+			remaining := []string{}
+			for _, step := range steps {
+				if !done[step.Name] {
+					remaining = append(remaining, step.Name)
+				}
+			}
+			return nil, fmt.Errorf("MATCH rules of steps %s depend on each other in a cycle", strings.Join(remaining, ", "))
+		}
+
+		sorted = append(sorted, steps[next])
+		done[steps[next].Name] = true
+	}
+
+	return sorted, nil
+}
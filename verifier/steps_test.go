@@ -0,0 +1,76 @@
+package verifier
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSortSteps(t *testing.T) {
+	step := func(name string, rules ...string) *Step {
+		return &Step{Name: name, ExpectedMaterials: rules}
+	}
+
+	tests := map[string]struct {
+		steps   []*Step
+		want    []string
+		wantErr string
+	}{
+		"listed order": {
+			steps: []*Step{step("clone"), step("test"), step("build")},
+			want:  []string{"clone", "test", "build"},
+		},
+		"after MATCH destinations": {
+			steps: []*Step{
+				step("package", "MATCH * WITH products FROM build"),
+				step("build", "MATCH * WITH products FROM clone"),
+				step("clone"),
+				step("lint"),
+			},
+			want: []string{"clone", "build", "package", "lint"},
+		},
+		"MATCH against itself or unknown steps": {
+			steps: []*Step{step("build", "MATCH * WITH products FROM build", "MATCH * WITH products FROM fetch"), step("clone")},
+			want:  []string{"build", "clone"},
+		},
+		"non-MATCH and invalid rules": {
+			steps: []*Step{step("build", "ALLOW *", "MATCH *"), step("clone")},
+			want:  []string{"build", "clone"},
+		},
+		"cycle": {
+			steps: []*Step{
+				step("clone"),
+				step("build", "MATCH * WITH products FROM package"),
+				step("package", "MATCH * WITH products FROM build"),
+			},
+			wantErr: "MATCH rules of steps build, package depend on each other in a cycle",
+		},
+		"duplicate names": {
+			steps:   []*Step{step("build"), step("clone"), step("build")},
+			wantErr: "more than one step is named build",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sorted, err := sortSteps(test.steps)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, step := range sorted {
+				names = append(names, step.Name)
+			}
+			if !slices.Equal(names, test.want) {
+				t.Errorf("got %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package verifier
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+func verifySubjects(logger *log.Entry, env *cel.Env, layout *Layout, subjects []*attestationv1.ResourceDescriptor, claims map[AttestationIdentifier][]*attestationv1.Statement, claimSources map[*attestationv1.Statement]string, report *Report) error {
+	for _, layoutSubject := range layout.Subjects {
+		matchedSubjects := getMatchingSubjects(subjects, layoutSubject.Subject)
+		if len(matchedSubjects) == 0 {
+			return fmt.Errorf("no subjects found matching %v", layoutSubject.Subject)
+		}
+
+		for _, subject := range matchedSubjects {
+			subjectReport := &SubjectReport{Name: subject.Name, Digest: subject.Digest}
+			report.Subjects = append(report.Subjects, subjectReport)
+
+			subjectStatements := getClaimsForSubject(subject, claims)
+
+			for _, expectedPredicate := range layoutSubject.ExpectedPredicates {
+				if expectedPredicate.Threshold == 0 {
+					expectedPredicate.Threshold = 1
+				}
+
+				functionaries, err := resolveFunctionaries(layout, expectedPredicate.Functionaries, expectedPredicate.Roles)
+				if err != nil {
+					return fmt.Errorf("for subject %s: %w", subject.Name, err)
+				}
+
+				predicateReport := &PredicateReport{
+					PredicateType: expectedPredicate.PredicateType,
+					Functionaries: functionaries,
+					Roles:         expectedPredicate.Roles,
+					Threshold:     expectedPredicate.Threshold,
+					Owners:        []string{},
+				}
+				subjectReport.Predicates = append(subjectReport.Predicates, predicateReport)
+
+				matchedPredicates := getPredicates(subjectStatements, expectedPredicate.PredicateType, functionaries)
+				if len(getOwners(layout, slices.Collect(maps.Keys(matchedPredicates)))) < expectedPredicate.Threshold {
+					return fmt.Errorf("threshold not met for subject %s", subject.Name)
+				}
+
+				failedChecks := []error{}
+				for _, functionary := range slices.Sorted(maps.Keys(matchedPredicates)) {
+					functionaryChecks := []error{}
+					accepted := false
+					for _, statement := range matchedPredicates[functionary] {
+						logger.Infof("Verifying claim for subject '%s' of type '%s' by '%s'...", subject.Name, expectedPredicate.PredicateType, functionary)
+						claimReport := &ClaimReport{Functionary: functionary, Owner: getOwner(layout, functionary), Attestation: claimSources[statement]}
+						predicateReport.Claims = append(predicateReport.Claims, claimReport)
+
+						input, err := getActivation(statement)
+						if err != nil {
+							return err
+						}
+
+						if err := applyAttributeRules(logger, env, input, expectedPredicate.ExpectedAttributes, &claimReport.Rules); err != nil {
+							err = fmt.Errorf("for subject %s, claim by %s failed attribute rules: %w", subject.Name, functionary, err)
+							functionaryChecks = append(functionaryChecks, err)
+							claimReport.Errors = append(claimReport.Errors, err.Error())
+							logger.Infof("Claim for subject %s of type %s by %s failed.", subject.Name, expectedPredicate.PredicateType, functionary)
+							continue
+						}
+
+						claimReport.Accepted = true
+						predicateReport.accept(claimReport.Owner)
+						accepted = true
+						logger.Info("Done.")
+						break
+					}
+					if !accepted {
+						failedChecks = append(failedChecks, functionaryChecks...)
+					}
+				}
+				if predicateReport.Accepted < expectedPredicate.Threshold {
+					return errors.Join(failedChecks...)
+				}
+				if err := checkRoleThresholds(layout, expectedPredicate.Roles, predicateReport.acceptedFunctionaries()); err != nil {
+					return errors.Join(append(failedChecks, fmt.Errorf("for subject %s: %w", subject.Name, err))...)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func getMatchingSubjects(subjects []*attestationv1.ResourceDescriptor, patterns []string) []*attestationv1.ResourceDescriptor {
+	matchedSubjects := []*attestationv1.ResourceDescriptor{}
+	for _, subject := range subjects {
+		for _, pattern := range patterns {
+			if matched, err := match(pattern, subject.Name); err == nil && matched {
+				matchedSubjects = append(matchedSubjects, subject)
+				break
+			}
+		}
+	}
+
+	return matchedSubjects
+}
+
+// getClaimsForSubject returns the verified claims, whether or not they're
+// claims for a step, that list an artifact with the same digest as subject
+// among their own subjects.
+func getClaimsForSubject(subject *attestationv1.ResourceDescriptor, claims map[AttestationIdentifier][]*attestationv1.Statement) map[AttestationIdentifier][]*attestationv1.Statement {
+	subjectClaims := map[AttestationIdentifier][]*attestationv1.Statement{}
+	for identifier, statements := range claims {
+		for _, statement := range statements {
+			for _, claimSubject := range statement.Subject {
+				if digestsMatch(subject.Digest, claimSubject.Digest) {
+					subjectClaims[identifier] = append(subjectClaims[identifier], statement)
+					break
+				}
+			}
+		}
+	}
+
+	return subjectClaims
+}
+
+// digestsMatch reports whether two digest sets share at least one algorithm
+// and agree on every algorithm they share.
+func digestsMatch(a, b map[string]string) bool {
+	common := 0
+	for algorithm, value := range a {
+		other, ok := b[algorithm]
+		if !ok {
+			continue
+		}
+		if value != other {
+			return false
+		}
+		common += 1
+	}
+
+	return common > 0
+}
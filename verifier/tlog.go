@@ -0,0 +1,138 @@
+package verifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore/pkg/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// rekorV1CheckpointOrigin matches the origin line of checkpoints issued by
+// Rekor v1, which ends with the log's tree ID.
+var rekorV1CheckpointOrigin = regexp.MustCompile(".* - [0-9]+$")
+
+// verifyTransparencyLog returns the attestation's transparency log entries
+// that record one of the envelope's signatures and are proven, entirely
+// offline, to be part of a log in the trusted root. Each entry must have an
+// inclusion proof against a checkpoint signed by the log, a signed entry
+// timestamp from the log, or both. Entries that can't be verified are logged
+// and ignored.
+func verifyTransparencyLog(logger *log.Entry, attestation *Attestation, trustedRoot root.TrustedMaterial) ([]*tlog.Entry, error) {
+	entries := []*tlog.Entry{}
+	if len(attestation.TlogEntries) == 0 || trustedRoot == nil {
+		return entries, nil
+	}
+
+	signatures := [][]byte{}
+	for _, sig := range attestation.Envelope.Signatures {
+		sigBytes, err := decodeSignature(sig)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, sigBytes)
+	}
+
+	rekorLogs := trustedRoot.RekorLogs()
+	for _, entry := range attestation.TlogEntries {
+		if err := verifyTransparencyLogEntry(entry, rekorLogs); err != nil {
+			logger.Infof("Unable to verify transparency log entry %d: %s", entry.LogIndex(), err)
+			continue
+		}
+
+		if !slices.ContainsFunc(signatures, func(sig []byte) bool { return bytes.Equal(sig, entry.Signature()) }) {
+			logger.Infof("Transparency log entry %d does not record the envelope's signature", entry.LogIndex())
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func verifyTransparencyLogEntry(entry *tlog.Entry, rekorLogs map[string]*root.TransparencyLog) error {
+	if err := tlog.ValidateEntry(entry); err != nil {
+		return err
+	}
+
+	rekorLog, ok := rekorLogs[hex.EncodeToString([]byte(entry.LogKeyID()))]
+	if !ok {
+		return fmt.Errorf("log is not in the trusted root")
+	}
+
+	if !entry.HasInclusionPromise() && !entry.HasInclusionProof() {
+		return fmt.Errorf("entry has neither an inclusion proof nor a signed entry timestamp")
+	}
+
+	if entry.HasInclusionPromise() {
+		if err := tlog.VerifySET(entry, rekorLogs); err != nil {
+			return err
+		}
+	}
+
+	if entry.HasInclusionProof() {
+		verifier, err := signature.LoadVerifier(rekorLog.PublicKey, rekorLog.SignatureHashFunc)
+		if err != nil {
+			return err
+		}
+
+		checkpoint := entry.TransparencyLogEntry().GetInclusionProof().GetCheckpoint().GetEnvelope()
+		if checkpointLines := strings.Split(checkpoint, "\n"); len(checkpointLines) >= 4 && rekorV1CheckpointOrigin.MatchString(checkpointLines[0]) {
+			return tlog.VerifyInclusion(entry, verifier)
+		}
+
+		logURL, err := url.Parse(rekorLog.BaseURL)
+		if err != nil {
+			return err
+		}
+		if logURL.Hostname() == "" {
+			return fmt.Errorf("log has no base URL in the trusted root to check the checkpoint's origin")
+		}
+
+		return tlog.VerifyCheckpointAndInclusion(entry, verifier, logURL.Hostname())
+	}
+
+	return nil
+}
+
+// getIntegratedTimes returns the times at which the log says the entries were
+// added. Only times covered by a signed entry timestamp are returned, as the
+// inclusion proof doesn't attest to them.
+func getIntegratedTimes(entries []*tlog.Entry) []time.Time {
+	integratedTimes := []time.Time{}
+	for _, entry := range entries {
+		if entry.HasInclusionPromise() && !entry.IntegratedTime().IsZero() {
+			integratedTimes = append(integratedTimes, entry.IntegratedTime())
+		}
+	}
+
+	return integratedTimes
+}
+
+// isLogged reports whether any of the entries records a signature by key,
+// either directly or through a certificate for it.
+func isLogged(entries []*tlog.Entry, key crypto.PublicKey) bool {
+	for _, entry := range entries {
+		loggedKey := entry.PublicKey()
+		if certificate, ok := loggedKey.(*x509.Certificate); ok {
+			loggedKey = certificate.PublicKey
+		}
+
+		if loggedKey, ok := loggedKey.(interface{ Equal(crypto.PublicKey) bool }); ok && loggedKey.Equal(key) {
+			return true
+		}
+	}
+
+	return false
+}
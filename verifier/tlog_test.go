@@ -0,0 +1,303 @@
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	rekorv1 "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	rekorutil "github.com/sigstore/rekor/pkg/util"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore/pkg/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// testTrustedRoot is a trusted root that only holds transparency logs and
+// Fulcio CAs.
+type testTrustedRoot struct {
+	root.BaseTrustedMaterial
+	rekorLogs map[string]*root.TransparencyLog
+	fulcioCAs []root.CertificateAuthority
+}
+
+func (r *testTrustedRoot) RekorLogs() map[string]*root.TransparencyLog {
+	return r.rekorLogs
+}
+
+func (r *testTrustedRoot) FulcioCertificateAuthorities() []root.CertificateAuthority {
+	return r.fulcioCAs
+}
+
+// testLog is a transparency log that records entries for tests.
+type testLog struct {
+	key *ecdsa.PrivateKey
+	id  []byte
+}
+
+func newTestLog(t *testing.T) *testLog {
+	t.Helper()
+
+	key := newTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := sha256.Sum256(der)
+
+	return &testLog{key: key, id: id[:]}
+}
+
+func (l *testLog) trustedRoot() *testTrustedRoot {
+	return &testTrustedRoot{rekorLogs: map[string]*root.TransparencyLog{
+		hex.EncodeToString(l.id): {
+			BaseURL:             "https://rekor.example.com",
+			ID:                  l.id,
+			ValidityPeriodStart: time.Now().Add(-24 * time.Hour),
+			HashFunc:            crypto.SHA256,
+			PublicKey:           l.key.Public(),
+			SignatureHashFunc:   crypto.SHA256,
+		},
+	}}
+}
+
+// record returns a hashedrekord entry for the envelope's signature by key,
+// with a signed entry timestamp, an inclusion proof in a log of that one
+// entry, or both.
+func (l *testLog) record(t *testing.T, envelope *dsse.Envelope, key *ecdsa.PrivateKey, withSET, withProof bool) *tlog.Entry {
+	t.Helper()
+
+	payload, err := envelope.DecodeB64Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(dsse.PAE(envelope.PayloadType, payload))
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"%s"}},"signature":{"content":"%s","publicKey":{"content":"%s"}}}}`,
+		hex.EncodeToString(digest[:]),
+		envelope.Signatures[0].Sig,
+		base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	)
+
+	tle := &rekorv1.TransparencyLogEntry{
+		LogIndex:          0,
+		LogId:             &protocommon.LogId{KeyId: l.id},
+		KindVersion:       &rekorv1.KindVersion{Kind: "hashedrekord", Version: "0.0.1"},
+		IntegratedTime:    time.Now().Unix(),
+		CanonicalizedBody: []byte(body),
+	}
+
+	if withSET {
+		rekorPayload, err := json.Marshal(tlog.RekorPayload{
+			Body:           base64.StdEncoding.EncodeToString(tle.CanonicalizedBody),
+			IntegratedTime: tle.IntegratedTime,
+			LogIndex:       tle.LogIndex,
+			LogID:          hex.EncodeToString(l.id),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		canonicalized, err := jsoncanonicalizer.Transform(rekorPayload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setDigest := sha256.Sum256(canonicalized)
+		set, err := ecdsa.SignASN1(rand.Reader, l.key, setDigest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		tle.InclusionPromise = &rekorv1.InclusionPromise{SignedEntryTimestamp: set}
+	}
+
+	if withProof {
+		// The root of a tree with one entry is the hash of that leaf.
+		rootHash := sha256.Sum256(append([]byte{0}, tle.CanonicalizedBody...))
+		signer, err := signature.LoadECDSASignerVerifier(l.key, crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkpoint, err := rekorutil.CreateAndSignCheckpoint(context.Background(), "rekor.example.com", 1, 1, rootHash[:], signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tle.InclusionProof = &rekorv1.InclusionProof{
+			LogIndex:   0,
+			RootHash:   rootHash[:],
+			TreeSize:   1,
+			Checkpoint: &rekorv1.Checkpoint{Envelope: string(checkpoint)},
+		}
+	}
+
+	entry, err := tlog.NewTlogEntry(tle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entry
+}
+
+func TestVerifyTransparencyLog(t *testing.T) {
+	key := newTestKey(t)
+	rekor := newTestLog(t)
+	otherLog := newTestLog(t)
+	// forged claims to be the trusted log, but signs with another key.
+	forged := &testLog{key: otherLog.key, id: rekor.id}
+
+	tests := map[string]struct {
+		entry     func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry
+		wantCount int
+	}{
+		"signed entry timestamp": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return rekor.record(t, envelope, key, true, false)
+			},
+			wantCount: 1,
+		},
+		"inclusion proof": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return rekor.record(t, envelope, key, false, true)
+			},
+			wantCount: 1,
+		},
+		"signed entry timestamp and inclusion proof": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return rekor.record(t, envelope, key, true, true)
+			},
+			wantCount: 1,
+		},
+		"neither": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return rekor.record(t, envelope, key, false, false)
+			},
+		},
+		"log not in the trusted root": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return otherLog.record(t, envelope, key, true, true)
+			},
+		},
+		"signed entry timestamp by another key": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return forged.record(t, envelope, key, true, false)
+			},
+		},
+		"tampered signed entry timestamp": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				entry := rekor.record(t, envelope, key, true, false)
+				entry.TransparencyLogEntry().IntegratedTime++
+				return entry
+			},
+		},
+		"inclusion proof with another root": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				entry := rekor.record(t, envelope, key, false, true)
+				entry.TransparencyLogEntry().InclusionProof.RootHash = make([]byte, sha256.Size)
+				return entry
+			},
+		},
+		"checkpoint by another key": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return forged.record(t, envelope, key, false, true)
+			},
+		},
+		"another signature": {
+			entry: func(t *testing.T, envelope *dsse.Envelope) *tlog.Entry {
+				return rekor.record(t, newSignedTestEnvelope(t, testStatement, key, ""), key, true, true)
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attestation := &Attestation{Envelope: newSignedTestEnvelope(t, testStatement, key, "")}
+			attestation.TlogEntries = []*tlog.Entry{test.entry(t, attestation.Envelope)}
+
+			entries, err := verifyTransparencyLog(log.NewEntry(log.New()), attestation, rekor.trustedRoot())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != test.wantCount {
+				t.Fatalf("got %d entries, want %d", len(entries), test.wantCount)
+			}
+			if test.wantCount > 0 && !isLogged(entries, key.Public()) {
+				t.Error("signature is not logged")
+			}
+		})
+	}
+}
+
+func TestVerifyRequireTransparencyLog(t *testing.T) {
+	key := newTestKey(t)
+	rekor := newTestLog(t)
+
+	functionary := newTestKeyFunctionary(t, key)
+	functionary.RequireTransparencyLog = true
+	layout := &Layout{
+		Expires:          time.Now().Add(time.Hour).Format(time.RFC3339),
+		Functionaries:    map[string]Functionary{"alice": functionary},
+		DefaultArtifacts: defaultArtifactsNone,
+		Steps: []*Step{{
+			Name:               "build",
+			ExpectedPredicates: []ExpectedStepPredicates{{PredicateType: "https://example.com/test/v1", Functionaries: []string{"alice"}}},
+		}},
+	}
+
+	tests := map[string]struct {
+		logged  func(t *testing.T, envelope *dsse.Envelope) []*tlog.Entry
+		wantErr string
+	}{
+		"logged": {
+			logged: func(t *testing.T, envelope *dsse.Envelope) []*tlog.Entry {
+				return []*tlog.Entry{rekor.record(t, envelope, key, true, true)}
+			},
+		},
+		"not logged": {
+			logged:  func(*testing.T, *dsse.Envelope) []*tlog.Entry { return nil },
+			wantErr: "no claims found for step build",
+		},
+		"another signature logged": {
+			logged: func(t *testing.T, envelope *dsse.Envelope) []*tlog.Entry {
+				return []*tlog.Entry{rekor.record(t, newSignedTestEnvelope(t, testStatement, key, ""), key, true, true)}
+			},
+			wantErr: "no claims found for step build",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attestation := &Attestation{Envelope: newSignedTestEnvelope(t, testStatement, key, "")}
+			attestation.TlogEntries = test.logged(t, attestation.Envelope)
+
+			_, err := Verify(layout, map[string]*Attestation{"build.alice": attestation}, nil, WithTrustedRoot(rekor.trustedRoot()))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+
+	if _, err := Verify(layout, map[string]*Attestation{}, nil); err == nil || !strings.Contains(err.Error(), "requires a trusted root") {
+		t.Errorf("got error %v without a trusted root, want it to be required", err)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"path"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,8 +20,49 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
-func Verify(layout *Layout, attestations map[string]*dsse.Envelope, parameters map[string]string) error {
-	log.Info("Verifying layout expiry...")
+// Verify checks the attestations against the layout. The returned report
+// records each check performed, and is returned even if verification fails.
+func Verify(layout *Layout, attestations map[string]*Attestation, parameters map[string]string, opts ...VerifyOption) (*Report, error) {
+	options := &verifyOptions{workspace: "."}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	report := &Report{}
+	if err := verify(newReportLogger(report), layout, attestations, parameters, options, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	report.Verified = true
+	return report, nil
+}
+
+// newReportLogger returns a logger for a single verification that writes
+// like the standard logger, and also records every message in the report.
+// Concurrent verifications each get their own logger, so their messages
+// don't end up in each other's reports.
+func newReportLogger(report *Report) *log.Entry {
+	standard := log.StandardLogger()
+
+	hooks := log.LevelHooks{}
+	for level, levelHooks := range standard.Hooks {
+		hooks[level] = append([]log.Hook{}, levelHooks...)
+	}
+	hooks.Add(report)
+
+	logger := log.New()
+	logger.SetOutput(standard.Out)
+	logger.SetFormatter(standard.Formatter)
+	logger.SetLevel(standard.GetLevel())
+	logger.SetReportCaller(standard.ReportCaller)
+	logger.ReplaceHooks(hooks)
+
+	return log.NewEntry(logger)
+}
+
+func verify(logger *log.Entry, layout *Layout, attestations map[string]*Attestation, parameters map[string]string, options *verifyOptions, report *Report) error {
+	logger.Info("Verifying layout expiry...")
 	expiry, err := time.Parse(time.RFC3339, layout.Expires)
 	if err != nil {
 		return err
@@ -27,38 +71,118 @@ func Verify(layout *Layout, attestations map[string]*dsse.Envelope, parameters m
 	if compare := expiry.Compare(time.Now()); compare == -1 {
 		return fmt.Errorf("layout has expired")
 	}
-	log.Info("Done.")
+	logger.Info("Done.")
 
 	if len(parameters) > 0 {
-		log.Info("Substituting parameters...")
+		logger.Info("Substituting parameters...")
 		layout, err = substituteParameters(layout, parameters)
 		if err != nil {
 			return err
 		}
-		log.Info("Done.")
+		logger.Info("Done.")
+	}
+
+	matchPolicy := layout.MatchPolicy
+	if matchPolicy == "" {
+		matchPolicy = matchPolicyAny
+	}
+	if !slices.Contains([]string{matchPolicyAny, matchPolicyAll, matchPolicyVerified}, matchPolicy) {
+		return fmt.Errorf("unknown match policy %s", matchPolicy)
 	}
 
-	log.Info("Fetching verifiers...")
-	verifiers, err := getVerifiers(layout.Functionaries)
+	defaultArtifacts := layout.DefaultArtifacts
+	if defaultArtifacts == "" {
+		defaultArtifacts = defaultArtifactsMaterials
+	}
+	if !slices.Contains([]string{defaultArtifactsMaterials, defaultArtifactsProducts, defaultArtifactsNone}, defaultArtifacts) {
+		return fmt.Errorf("unknown default artifacts %s", defaultArtifacts)
+	}
+
+	if options.source != nil {
+		logger.Info("Fetching attestations...")
+		fetched, err := fetchAttestations(context.Background(), logger, options.source, options.subjects)
+		if err != nil {
+			return err
+		}
+		attestations = maps.Clone(attestations)
+		if attestations == nil {
+			attestations = map[string]*Attestation{}
+		}
+		maps.Copy(attestations, fetched)
+		logger.Info("Done.")
+	}
+
+	logger.Info("Fetching verifiers...")
+	functionaries, err := loadFunctionaries(layout.Functionaries)
 	if err != nil {
 		return err
 	}
-	envVerifier, err := dsse.NewEnvelopeVerifier(verifiers...)
+	loadedLayout := *layout
+	loadedLayout.Functionaries = functionaries
+	loadedLayout.MatchPolicy = matchPolicy
+	loadedLayout.DefaultArtifacts = defaultArtifacts
+	layout = &loadedLayout
+
+	verifiers, err := getVerifiers(logger, layout.Functionaries)
 	if err != nil {
 		return err
 	}
-	log.Info("Done.")
 
-	log.Info("Loading attestations as claims...")
-	claims := map[string]map[AttestationIdentifier]*attestationv1.Statement{}
-	for attestationName, env := range attestations {
-		stepName := getStepName(attestationName)
-		if claims[stepName] == nil {
-			claims[stepName] = map[AttestationIdentifier]*attestationv1.Statement{}
+	for _, functionary := range layout.Functionaries {
+		if (functionary.KeyType == sigstoreKeyType || functionary.RequireTransparencyLog) && options.trustedRoot == nil {
+			return fmt.Errorf("functionary %s requires a trusted root", functionary.KeyID)
 		}
+	}
+	logger.Info("Done.")
+
+	env, err := getCELEnv()
+	if err != nil {
+		return err
+	}
 
-		acceptedKeys, err := envVerifier.Verify(context.Background(), env)
+	binder, err := newStepBinder(env, layout)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Loading attestations as claims...")
+	// A functionary may make several claims of the same type for a step, for
+	// example when several provenances bind to it by content, so every one
+	// of them is kept, in the order of the attestations' names.
+	claims := map[string]map[AttestationIdentifier][]*attestationv1.Statement{}
+	// verifiedClaims holds every statement with a verified signature, even
+	// those that aren't claims for any step, for the layout's subjects.
+	verifiedClaims := map[AttestationIdentifier][]*attestationv1.Statement{}
+	// claimSources records the attestation each statement came from, so
+	// that the report can say which attestations led to accepted claims.
+	claimSources := map[*attestationv1.Statement]string{}
+	for _, attestationName := range slices.Sorted(maps.Keys(attestations)) {
+		attestation := attestations[attestationName]
+		logEntries, err := verifyTransparencyLog(logger, attestation, options.trustedRoot)
 		if err != nil {
+			return err
+		}
+
+		acceptedKeys := []dsse.AcceptedKey{}
+		if len(verifiers) > 0 {
+			envVerifier, err := dsse.NewEnvelopeVerifier(verifiers...)
+			if err != nil {
+				return err
+			}
+
+			keys, err := envVerifier.Verify(context.Background(), attestation.Envelope)
+			if err == nil {
+				acceptedKeys = append(acceptedKeys, keys...)
+			}
+		}
+
+		certificateKeys, err := getCertificateKeys(logger, layout.Functionaries, attestation, logEntries, options.trustedRoot)
+		if err != nil {
+			return err
+		}
+		acceptedKeys = append(acceptedKeys, certificateKeys...)
+
+		if len(acceptedKeys) == 0 {
 			// The verifier loads all attestations and verifies their
 			// signatures. It represents their claims in the format "<signer>
 			// says <claim> for <step>", allowing policy to be written as "does
@@ -71,11 +195,11 @@ func Verify(layout *Layout, attestations map[string]*dsse.Envelope, parameters m
 			// from the layout.  If we encounter an attestation signed by an
 			// unrecognized key, the verifier logs this and moves on. This
 			// attestation is not considered for further verification.
-			log.Infof("Unable to verify %s's signatures", attestationName)
+			logger.Infof("Unable to verify %s's signatures", attestationName)
 			continue
 		}
 
-		sb, err := env.DecodeB64Payload()
+		sb, err := attestation.Envelope.DecodeB64Payload()
 		if err != nil {
 			return err
 		}
@@ -85,18 +209,46 @@ func Verify(layout *Layout, attestations map[string]*dsse.Envelope, parameters m
 			return err
 		}
 
+		stepNames, err := binder.getStepNames(logger, attestationName, statement)
+		if err != nil {
+			return err
+		}
+		claimSources[statement] = attestationName
+
 		for _, ak := range acceptedKeys {
-			claims[stepName][AttestationIdentifier{Functionary: ak.KeyID, PredicateType: statement.PredicateType}] = statement
+			// A functionary that must log its signatures only vouches for
+			// the claim if the signature's in the transparency log, so a
+			// stolen key can't produce accepted claims unnoticed.
+			if functionary, ok := getFunctionary(layout.Functionaries, ak.KeyID); ok && functionary.RequireTransparencyLog && !isLogged(logEntries, ak.Public) {
+				logger.Infof("Signature on %s by %s is not recorded in a transparency log", attestationName, ak.KeyID)
+				continue
+			}
+
+			identifier := AttestationIdentifier{Functionary: ak.KeyID, PredicateType: statement.PredicateType}
+			verifiedClaims[identifier] = append(verifiedClaims[identifier], statement)
+			for _, stepName := range stepNames {
+				if claims[stepName] == nil {
+					claims[stepName] = map[AttestationIdentifier][]*attestationv1.Statement{}
+				}
+				claims[stepName][identifier] = append(claims[stepName][identifier], statement)
+			}
 		}
 	}
-	log.Info("Done.")
+	logger.Info("Done.")
 
-	env, err := getCELEnv()
+	steps, err := sortSteps(layout.Steps)
 	if err != nil {
 		return err
 	}
 
-	for _, step := range layout.Steps {
+	// MATCH rules only look for artifacts in the claims that have been
+	// accepted for their own step, which sortSteps ensures happens first.
+	acceptedClaims := map[string]map[AttestationIdentifier]*attestationv1.Statement{}
+
+	for _, step := range steps {
+		stepReport := &StepReport{Name: step.Name}
+		report.Steps = append(report.Steps, stepReport)
+
 		stepStatements, ok := claims[step.Name]
 		if !ok {
 			return fmt.Errorf("no claims found for step %s", step.Name)
@@ -107,55 +259,140 @@ func Verify(layout *Layout, attestations map[string]*dsse.Envelope, parameters m
 				expectedPredicate.Threshold = 1
 			}
 
-			matchedPredicates := getPredicates(stepStatements, expectedPredicate.PredicateType, expectedPredicate.Functionaries)
-			if len(matchedPredicates) < expectedPredicate.Threshold {
+			functionaries, err := resolveFunctionaries(layout, expectedPredicate.Functionaries, expectedPredicate.Roles)
+			if err != nil {
+				return fmt.Errorf("for step %s: %w", step.Name, err)
+			}
+
+			predicateReport := &PredicateReport{
+				PredicateType: expectedPredicate.PredicateType,
+				Functionaries: functionaries,
+				Roles:         expectedPredicate.Roles,
+				Threshold:     expectedPredicate.Threshold,
+				Owners:        []string{},
+			}
+			stepReport.Predicates = append(stepReport.Predicates, predicateReport)
+
+			matchedPredicates := getPredicates(stepStatements, expectedPredicate.PredicateType, functionaries)
+			if len(getOwners(layout, slices.Collect(maps.Keys(matchedPredicates)))) < expectedPredicate.Threshold {
 				return fmt.Errorf("threshold not met for step %s", step.Name)
 			}
 
 			failedChecks := []error{}
-			acceptedPredicates := 0
-			for functionary, statement := range matchedPredicates {
-				log.Infof("Verifying claim for step '%s' of type '%s' by '%s'...", step.Name, expectedPredicate.PredicateType, functionary)
-				failed := false
-
-				if err := applyArtifactRules(statement, step.ExpectedMaterials, step.ExpectedProducts, claims); err != nil {
-					failed = true
-					failedChecks = append(failedChecks, fmt.Errorf("for step %s, claim by %s failed artifact rules: %w", step.Name, functionary, err))
+			// acceptedStatements holds the first of each functionary's
+			// claims that passes the step's checks.
+			acceptedStatements := map[string]*attestationv1.Statement{}
+			for _, functionary := range slices.Sorted(maps.Keys(matchedPredicates)) {
+				functionaryChecks := []error{}
+				for _, statement := range matchedPredicates[functionary] {
+					logger.Infof("Verifying claim for step '%s' of type '%s' by '%s'...", step.Name, expectedPredicate.PredicateType, functionary)
+					claimReport := &ClaimReport{Functionary: functionary, Owner: getOwner(layout, functionary), Attestation: claimSources[statement]}
+					predicateReport.Claims = append(predicateReport.Claims, claimReport)
+
+					checks := []error{}
+					if err := applyCommandRule(logger, statement, step, &claimReport.Rules); err != nil {
+						checks = append(checks, fmt.Errorf("for step %s, claim by %s failed command check: %w", step.Name, functionary, err))
+					}
+
+					if err := applyArtifactRules(logger, statement, step.ExpectedMaterials, step.ExpectedProducts, acceptedClaims, layout, &claimReport.Rules); err != nil {
+						checks = append(checks, fmt.Errorf("for step %s, claim by %s failed artifact rules: %w", step.Name, functionary, err))
+					}
+
+					input, err := getActivation(statement)
+					if err != nil {
+						return err
+					}
+
+					if err := applyAttributeRules(logger, env, input, expectedPredicate.ExpectedAttributes, &claimReport.Rules); err != nil {
+						checks = append(checks, fmt.Errorf("for step %s, claim by %s failed attribute rules: %w", step.Name, functionary, err))
+					}
+
+					if len(checks) > 0 {
+						functionaryChecks = append(functionaryChecks, checks...)
+						for _, check := range checks {
+							claimReport.Errors = append(claimReport.Errors, check.Error())
+						}
+						logger.Infof("Claim for step %s of type %s by %s failed.", step.Name, expectedPredicate.PredicateType, functionary)
+						continue
+					}
+
+					claimReport.Accepted = true
+					predicateReport.accept(claimReport.Owner)
+					acceptedStatements[functionary] = statement
+					logger.Info("Done.")
+					break
 				}
-
-				input, err := getActivation(statement)
-				if err != nil {
-					return err
+				if _, ok := acceptedStatements[functionary]; !ok {
+					failedChecks = append(failedChecks, functionaryChecks...)
 				}
-
-				if err := applyAttributeRules(env, input, expectedPredicate.ExpectedAttributes); err != nil {
-					failed = true
-					failedChecks = append(failedChecks, fmt.Errorf("for step %s, claim by %s failed attribute rules: %w", step.Name, functionary, err))
+			}
+			if expectedPredicate.Agreement != nil {
+				disagreements, err := applyAgreement(logger, env, expectedPredicate.Agreement, acceptedStatements, layout.DefaultArtifacts, predicateReport)
+				if err != nil {
+					return fmt.Errorf("for step %s: %w", step.Name, err)
 				}
-
-				if failed {
-					log.Infof("Claim for step %s of type %s by %s failed.", step.Name, expectedPredicate.PredicateType, functionary)
-				} else {
-					acceptedPredicates += 1
-					log.Info("Done.")
+				for _, disagreement := range disagreements {
+					failedChecks = append(failedChecks, fmt.Errorf("for step %s, %w", step.Name, disagreement))
 				}
 			}
-			if acceptedPredicates < expectedPredicate.Threshold {
+			if predicateReport.Accepted < expectedPredicate.Threshold {
 				return errors.Join(failedChecks...)
 			}
+			if err := checkRoleThresholds(layout, expectedPredicate.Roles, predicateReport.acceptedFunctionaries()); err != nil {
+				return errors.Join(append(failedChecks, fmt.Errorf("for step %s: %w", step.Name, err))...)
+			}
+
+			if acceptedClaims[step.Name] == nil {
+				acceptedClaims[step.Name] = map[AttestationIdentifier]*attestationv1.Statement{}
+			}
+			for _, claimReport := range predicateReport.Claims {
+				if claimReport.Accepted {
+					acceptedClaims[step.Name][AttestationIdentifier{Functionary: claimReport.Functionary, PredicateType: expectedPredicate.PredicateType}] = acceptedStatements[claimReport.Functionary]
+				}
+			}
+		}
+	}
+
+	if len(layout.Subjects) > 0 {
+		logger.Info("Verifying subjects...")
+		if err := verifySubjects(logger, env, layout, options.subjects, verifiedClaims, claimSources, report); err != nil {
+			return err
 		}
+		logger.Info("Done.")
 	}
 
-	log.Info("Verification successful!")
+	if len(layout.Inspections) > 0 {
+		logger.Info("Verifying inspections...")
+		if err := verifyInspections(logger, env, layout.Inspections, options.workspace, acceptedClaims, layout, report); err != nil {
+			return err
+		}
+		logger.Info("Done.")
+	}
+
+	logger.Info("Verification successful!")
 
 	return nil
 }
 
-func getVerifiers(publicKeys map[string]Functionary) ([]dsse.Verifier, error) {
+func getVerifiers(logger *log.Entry, publicKeys map[string]Functionary) ([]dsse.Verifier, error) {
 	verifiers := []dsse.Verifier{}
 
 	for _, key := range publicKeys {
-		log.Infof("Creating verifier for key %s", key.KeyID)
+		if isCertificateKeyType(key.KeyType) {
+			continue
+		}
+
+		logger.Infof("Creating verifier for key %s", key.KeyID)
+		if key.KeyType == openPGPKeyType {
+			verifier, err := newOpenPGPVerifier(key)
+			if err != nil {
+				return nil, err
+			}
+
+			verifiers = append(verifiers, verifier)
+			continue
+		}
+
 		sslibKey := &signerverifier.SSLibKey{
 			KeyIDHashAlgorithms: key.KeyIDHashAlgorithms,
 			KeyType:             key.KeyType,
@@ -166,41 +403,24 @@ func getVerifiers(publicKeys map[string]Functionary) ([]dsse.Verifier, error) {
 			KeyID:  key.KeyID,
 		}
 
-		switch key.KeyType { // TODO: use scheme
-		case "rsa":
-			verifier, err := signerverifier.NewRSAPSSSignerVerifierFromSSLibKey(sslibKey)
-			if err != nil {
-				return nil, err
-			}
-
-			verifiers = append(verifiers, verifier)
-		case "ecdsa":
-			verifier, err := signerverifier.NewECDSASignerVerifierFromSSLibKey(sslibKey)
-			if err != nil {
-				return nil, err
-			}
-
-			verifiers = append(verifiers, verifier)
-		case "ed25519":
-			verifier, err := signerverifier.NewED25519SignerVerifierFromSSLibKey(sslibKey)
-			if err != nil {
-				return nil, err
-			}
-
-			verifiers = append(verifiers, verifier)
+		verifier, err := newVerifier(sslibKey)
+		if err != nil {
+			return nil, err
 		}
+
+		verifiers = append(verifiers, verifier)
 	}
 
 	return verifiers, nil
 }
 
-func getPredicates(statements map[AttestationIdentifier]*attestationv1.Statement, predicateType string, functionaries []string) map[string]*attestationv1.Statement {
-	matchedPredicates := map[string]*attestationv1.Statement{}
+func getPredicates(statements map[AttestationIdentifier][]*attestationv1.Statement, predicateType string, functionaries []string) map[string][]*attestationv1.Statement {
+	matchedPredicates := map[string][]*attestationv1.Statement{}
 
 	for _, keyID := range functionaries {
-		statement, ok := statements[AttestationIdentifier{PredicateType: predicateType, Functionary: keyID}]
+		matched, ok := statements[AttestationIdentifier{PredicateType: predicateType, Functionary: keyID}]
 		if ok {
-			matchedPredicates[keyID] = statement
+			matchedPredicates[keyID] = matched
 		}
 	}
 
@@ -213,20 +433,48 @@ func getCELEnv() (*cel.Env, error) {
 		cel.Variable("subject", cel.ListType(cel.ObjectType("in_toto_attestation.v1.ResourceDescriptor"))),
 		cel.Variable("predicateType", cel.StringType),
 		cel.Variable("predicate", cel.ObjectType("google.protobuf.Struct")),
+		cel.Variable("byproducts", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("components", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		cel.Variable("licenses", cel.ListType(cel.StringType)),
+		cel.Variable("purls", cel.ListType(cel.StringType)),
 	)
 }
 
 func getActivation(statement *attestationv1.Statement) (interpreter.Activation, error) {
+	byproducts, err := getByproducts(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	components, licenses, purls, err := getComponentVariables(statement)
+	if err != nil {
+		return nil, err
+	}
+
 	return interpreter.NewActivation(map[string]any{
 		"type":          statement.Type,
 		"subject":       statement.Subject,
 		"predicateType": statement.PredicateType,
 		"predicate":     statement.Predicate,
+		"byproducts":    byproducts,
+		"components":    components,
+		"licenses":      licenses,
+		"purls":         purls,
 	})
 }
 
+func getFunctionary(functionaries map[string]Functionary, keyID string) (Functionary, bool) {
+	for _, functionary := range functionaries {
+		if functionary.KeyID == keyID {
+			return functionary, true
+		}
+	}
+
+	return Functionary{}, false
+}
+
 func getStepName(name string) string {
-	nameS := strings.Split(name, ".")
+	nameS := strings.Split(path.Base(name), ".")
 	nameS = nameS[:len(nameS)-1]
 	return strings.Join(nameS, ".")
 }
@@ -252,6 +500,8 @@ func substituteParameters(layout *Layout, parameters map[string]string) (*Layout
 	replacer := strings.NewReplacer(replacementDirectives...)
 
 	for _, step := range layout.Steps {
+		step.Command = replace(replacer, step.Command)
+
 		for i, materialRule := range step.ExpectedMaterials {
 			step.ExpectedMaterials[i] = replace(replacer, materialRule)
 		}
@@ -272,6 +522,44 @@ func substituteParameters(layout *Layout, parameters map[string]string) (*Layout
 		}
 	}
 
+	for _, subject := range layout.Subjects {
+		for i, pattern := range subject.Subject {
+			subject.Subject[i] = replace(replacer, pattern)
+		}
+
+		for _, predicateType := range subject.ExpectedPredicates {
+			for i, attributeRule := range predicateType.ExpectedAttributes {
+				predicateType.ExpectedAttributes[i] = Constraint{
+					Rule:           replace(replacer, attributeRule.Rule),
+					AllowIfNoClaim: attributeRule.AllowIfNoClaim,
+					Warn:           attributeRule.Warn,
+					Debug:          replace(replacer, attributeRule.Debug),
+				}
+			}
+		}
+	}
+
+	for _, inspection := range layout.Inspections {
+		inspection.Command = replace(replacer, inspection.Command)
+
+		for i, materialRule := range inspection.ExpectedMaterials {
+			inspection.ExpectedMaterials[i] = replace(replacer, materialRule)
+		}
+
+		for i, productRule := range inspection.ExpectedProducts {
+			inspection.ExpectedProducts[i] = replace(replacer, productRule)
+		}
+
+		for i, attributeRule := range inspection.ExpectedAttributes {
+			inspection.ExpectedAttributes[i] = Constraint{
+				Rule:           replace(replacer, attributeRule.Rule),
+				AllowIfNoClaim: attributeRule.AllowIfNoClaim,
+				Warn:           attributeRule.Warn,
+				Debug:          replace(replacer, attributeRule.Debug),
+			}
+		}
+	}
+
 	return layout, nil
 }
 
@@ -0,0 +1,178 @@
+package verifier
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// blockingHook holds up the first message logged until released.
+type blockingHook struct {
+	fired    atomic.Bool
+	blocked  chan struct{}
+	released chan struct{}
+}
+
+func (h *blockingHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *blockingHook) Fire(*log.Entry) error {
+	if h.fired.CompareAndSwap(false, true) {
+		close(h.blocked)
+		<-h.released
+	}
+	return nil
+}
+
+func TestVerifyConcurrentReports(t *testing.T) {
+	hook := &blockingHook{blocked: make(chan struct{}), released: make(chan struct{})}
+	hooks := log.LevelHooks{}
+	hooks.Add(hook)
+	previousHooks := log.StandardLogger().ReplaceHooks(hooks)
+	t.Cleanup(func() { log.StandardLogger().ReplaceHooks(previousHooks) })
+
+	// The layout has expired, so each verification stops after its first
+	// message.
+	layout := &Layout{Expires: "2000-01-01T00:00:00Z"}
+
+	first := make(chan *Report)
+	go func() {
+		report, _ := Verify(layout, nil, nil)
+		first <- report
+	}()
+
+	// Run a second verification while the first is in the middle of
+	// logging.
+	<-hook.blocked
+	second, _ := Verify(layout, nil, nil)
+	close(hook.released)
+
+	for name, report := range map[string]*Report{"first": <-first, "second": second} {
+		if len(report.Messages) != 1 {
+			t.Errorf("%s report: got messages %v, want only its own", name, report.Messages)
+		}
+	}
+	if got := log.StandardLogger().Hooks; len(got) != len(hooks) || len(got[log.InfoLevel]) != 1 {
+		t.Errorf("got standard logger hooks %v, want them unchanged", got)
+	}
+}
+
+// newTestKeyFunctionary returns a functionary for the public half of key.
+func newTestKeyFunctionary(t *testing.T, key *ecdsa.PrivateKey) Functionary {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Functionary{KeyVal: KeyVal{Public: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))}}
+}
+
+func TestVerifyMultipleClaimsByFunctionary(t *testing.T) {
+	key := newTestKey(t)
+	provenance := func(builder string) string {
+		return `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "app.tgz", "digest": {"sha256": "aa"}}], "predicateType": "https://example.com/provenance/v1", "predicate": {"builder": "` + builder + `"}}`
+	}
+
+	layout := &Layout{
+		Expires:          time.Now().Add(time.Hour).Format(time.RFC3339),
+		Functionaries:    map[string]Functionary{"alice": newTestKeyFunctionary(t, key)},
+		StepBinding:      stepBindingContent,
+		DefaultArtifacts: defaultArtifactsNone,
+		Steps: []*Step{{
+			Name:    "build",
+			Binding: &StepBinding{PredicateType: "https://example.com/provenance/v1"},
+			ExpectedPredicates: []ExpectedStepPredicates{{
+				PredicateType:      "https://example.com/provenance/v1",
+				Functionaries:      []string{"alice"},
+				ExpectedAttributes: []Constraint{{Rule: `predicate.builder == "trusted"`}},
+			}},
+		}},
+	}
+
+	tests := map[string]struct {
+		builders []string
+		wantErr  bool
+	}{
+		"first passes":  {builders: []string{"trusted", "other"}},
+		"second passes": {builders: []string{"other", "trusted"}},
+		"none passes":   {builders: []string{"other", "unknown"}, wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attestations := map[string]*Attestation{}
+			for i, builder := range test.builders {
+				attestations["provenance."+strconv.Itoa(i)] = &Attestation{Envelope: newSignedTestEnvelope(t, provenance(builder), key, "")}
+			}
+
+			report, err := Verify(layout, attestations, nil)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims := report.Steps[0].Predicates[0].Claims
+			accepted := slices.IndexFunc(claims, func(claim *ClaimReport) bool { return claim.Accepted })
+			if want := "provenance." + strconv.Itoa(slices.Index(test.builders, "trusted")); accepted < 0 || claims[accepted].Attestation != want {
+				t.Errorf("got claims %v, want %s accepted", claims, want)
+			}
+		})
+	}
+}
+
+func TestVerifySubjectsUnboundClaims(t *testing.T) {
+	key := newTestKey(t)
+	statement := func(predicateType, predicate string) string {
+		return `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "app.tgz", "digest": {"sha256": "aa"}}], "predicateType": "` + predicateType + `", "predicate": ` + predicate + `}`
+	}
+
+	attestations := map[string]*Attestation{
+		"build.provenance": {Envelope: newSignedTestEnvelope(t, statement("https://example.com/provenance/v1", `{"name": "build"}`), key, "")},
+		"scan":             {Envelope: newSignedTestEnvelope(t, statement("https://example.com/scan/v1", `{}`), key, "")},
+	}
+	subject := &attestationv1.ResourceDescriptor{Name: "app.tgz", Digest: map[string]string{"sha256": "aa"}}
+
+	// The scan is about the final artifact, so it's a claim for the
+	// subject without binding to any step.
+	for _, stepBinding := range []string{stepBindingFilename, stepBindingPredicate, stepBindingContent} {
+		t.Run(stepBinding, func(t *testing.T) {
+			layout := &Layout{
+				Expires:          time.Now().Add(time.Hour).Format(time.RFC3339),
+				Functionaries:    map[string]Functionary{"alice": newTestKeyFunctionary(t, key)},
+				StepBinding:      stepBinding,
+				DefaultArtifacts: defaultArtifactsNone,
+				Subjects: []*Subject{{
+					Subject:            []string{"app.tgz"},
+					ExpectedPredicates: []ExpectedSubjectPredicates{{PredicateType: "https://example.com/scan/v1", Functionaries: []string{"alice"}}},
+				}},
+			}
+			if stepBinding == stepBindingContent {
+				layout.Steps = []*Step{{
+					Name:               "build",
+					Binding:            &StepBinding{PredicateType: "https://example.com/provenance/v1"},
+					ExpectedPredicates: []ExpectedStepPredicates{{PredicateType: "https://example.com/provenance/v1", Functionaries: []string{"alice"}}},
+				}}
+			}
+
+			if _, err := Verify(layout, attestations, nil, WithSubjects(subject)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
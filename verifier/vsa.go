@@ -0,0 +1,97 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	vsav1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	attestationv1 "github.com/in-toto/attestation/go/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	VSAPredicateType = "https://slsa.dev/verification_summary/v1"
+	VerifierID       = "https://github.com/in-toto/attestation-verifier"
+
+	statementPayloadType = "application/vnd.in-toto+json"
+)
+
+// GenerateVSA returns a statement summarizing a successful verification of
+// subjects against the layout identified by policy. Only the attestations that
+// accepted claims in the report came from are listed as inputs, each
+// identified by its name and the SHA-256 digest of the bytes it was read
+// from, or of its envelope's JSON serialization if it wasn't read from any.
+func GenerateVSA(layout *Layout, policy *vsav1.VerificationSummary_Policy, resourceURI string, subjects []*attestationv1.ResourceDescriptor, attestations map[string]*Attestation, report *Report) (*attestationv1.Statement, error) {
+	names := report.acceptedAttestations()
+	inputAttestations := make([]*vsav1.VerificationSummary_InputAttestation, 0, len(names))
+	for _, name := range names {
+		attestation, ok := attestations[name]
+		if !ok {
+			return nil, fmt.Errorf("attestation %s is not among the verified attestations", name)
+		}
+
+		attestationBytes := attestation.Raw
+		if attestationBytes == nil {
+			var err error
+			attestationBytes, err = json.Marshal(attestation.Envelope)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		digest := sha256.Sum256(attestationBytes)
+		inputAttestations = append(inputAttestations, &vsav1.VerificationSummary_InputAttestation{
+			Uri:    name,
+			Digest: map[string]string{"sha256": hex.EncodeToString(digest[:])},
+		})
+	}
+
+	vsa := &vsav1.VerificationSummary{
+		Verifier:           &vsav1.VerificationSummary_Verifier{Id: VerifierID},
+		TimeVerified:       timestamppb.New(time.Now()),
+		ResourceUri:        resourceURI,
+		Policy:             policy,
+		InputAttestations:  inputAttestations,
+		VerificationResult: "PASSED",
+		VerifiedLevels:     layout.VerifiedLevels,
+	}
+
+	vsaBytes, err := protojson.Marshal(vsa)
+	if err != nil {
+		return nil, err
+	}
+
+	predicate := &structpb.Struct{}
+	if err := protojson.Unmarshal(vsaBytes, predicate); err != nil {
+		return nil, err
+	}
+
+	return &attestationv1.Statement{
+		Type:          attestationv1.StatementTypeUri,
+		Subject:       subjects,
+		PredicateType: VSAPredicateType,
+		Predicate:     predicate,
+	}, nil
+}
+
+// SignStatement wraps statement in a DSSE envelope signed by signer.
+func SignStatement(statement *attestationv1.Statement, signer dsse.Signer) (*dsse.Envelope, error) {
+	statementBytes, err := protojson.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	envSigner, err := dsse.NewEnvelopeSigner(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return envSigner.SignPayload(context.Background(), statementPayloadType, statementBytes)
+}
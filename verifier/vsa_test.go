@@ -0,0 +1,56 @@
+package verifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	vsav1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestGenerateVSAInputAttestations(t *testing.T) {
+	statement := `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "foo", "digest": {"sha256": "aa"}}], "predicateType": "https://example.com/test/v1", "predicate": {}}`
+	// Pretty-printed, so that re-serializing the envelope would give a
+	// different digest.
+	accepted := []byte(strings.Replace(newTestEnvelope(t, statement), ",", ",\n  ", -1) + "\n")
+	rejected := []byte(newTestEnvelope(t, statement))
+
+	attestations := map[string]*Attestation{}
+	for name, contents := range map[string][]byte{"build": accepted, "stray": rejected} {
+		parsed, err := ParseAttestations(contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestations[name] = parsed[0]
+	}
+
+	report := &Report{Steps: []*StepReport{{
+		Name: "build",
+		Predicates: []*PredicateReport{{Claims: []*ClaimReport{
+			{Functionary: "alice", Attestation: "build", Accepted: true},
+			{Functionary: "bob", Attestation: "stray"},
+		}}},
+	}}}
+
+	statementVSA, err := GenerateVSA(&Layout{}, &vsav1.VerificationSummary_Policy{}, "", nil, attestations, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	predicateBytes, err := protojson.Marshal(statementVSA.Predicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vsa := &vsav1.VerificationSummary{}
+	if err := protojson.Unmarshal(predicateBytes, vsa); err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(accepted)
+	inputs := vsa.GetInputAttestations()
+	if len(inputs) != 1 || inputs[0].GetUri() != "build" || inputs[0].GetDigest()["sha256"] != hex.EncodeToString(digest[:]) {
+		t.Errorf("got input attestations %v, want build with the digest of its file", inputs)
+	}
+}